@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gympass/go-giter8/props"
+)
+
+type cliArgs struct {
+	repo        string
+	target      string
+	scaffold    string
+	options     props.Pairs
+	answersPath string
+	archivePath string
+	onConflict  string
+	dryRun      string
+	noInput     bool
+	format      bool
+	verbose     bool
+}
+
+// flagValue returns the value of a flag provided either as --flag=value or as
+// --flag value, advancing idx in the latter case.
+func flagValue(name, inline string, hasInline bool, args []string, idx *int) string {
+	if hasInline {
+		return inline
+	}
+	if *idx+1 >= len(args) {
+		fatalf("Flag `--%s' requires a value. Run gg8 with --help for further information", name)
+	}
+	*idx++
+	return args[*idx]
+}
+
+func parseArgs(args []string) cliArgs {
+	var result cliArgs
+	takingOpts := false
+	scaffoldMode := len(args) > 0 && args[0] == "scaffold"
+	if scaffoldMode {
+		args = args[1:]
+	}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			if scaffoldMode {
+				if result.scaffold == "" {
+					fatalf("Found `--' before scaffold argument. Run gg8 with --help for further information")
+				}
+				takingOpts = true
+				continue
+			}
+			if result.repo == "" {
+				fatalf("Found `--' before repository argument. Run gg8 with --help for further information")
+			}
+			if result.target == "" && result.archivePath == "" {
+				fatalf("Found `--' before destination argument. Run gg8 with --help for further information")
+			}
+			takingOpts = true
+			continue
+		}
+
+		if !takingOpts && (len(args) == 1 || strings.HasPrefix(arg, "-")) && helpRegexp.MatchString(arg) {
+			usage()
+			os.Exit(0)
+		}
+
+		if !takingOpts && strings.HasPrefix(arg, "--") {
+			name := strings.TrimPrefix(arg, "--")
+			value, hasValue := "", false
+			if idx := strings.Index(name, "="); idx != -1 {
+				name, value, hasValue = name[0:idx], name[idx+1:], true
+			}
+			switch name {
+			case "answers":
+				result.answersPath = flagValue(name, value, hasValue, args, &i)
+			case "output-archive":
+				result.archivePath = flagValue(name, value, hasValue, args, &i)
+			case "on-conflict":
+				result.onConflict = flagValue(name, value, hasValue, args, &i)
+			case "no-input":
+				result.noInput = true
+			case "format":
+				result.format = true
+			case "verbose":
+				result.verbose = true
+			case "dry-run":
+				// The format is optional, and therefore can only be provided inline
+				result.dryRun = "tree"
+				if hasValue {
+					result.dryRun = value
+				}
+			default:
+				fatalf("Unknown flag `%s'. Run gg8 with --help for further information", arg)
+			}
+			continue
+		}
+
+		if scaffoldMode && !takingOpts {
+			if result.scaffold != "" {
+				fatalf("Unexpected param `%s`. Run gg8 with --help for further information", arg)
+			}
+			result.scaffold = arg
+			continue
+		}
+
+		if !scaffoldMode && result.repo == "" {
+			if githubRepositoryRegexp.MatchString(arg) {
+				suffix := ""
+				if !strings.HasSuffix(arg, ".git") {
+					suffix = ".git"
+				}
+				result.repo = fmt.Sprintf("https://github.com/%s%s", arg, suffix)
+			} else {
+				result.repo = arg
+			}
+			continue
+		}
+
+		if !takingOpts && result.target == "" {
+			result.target = arg
+			continue
+		}
+
+		if !takingOpts {
+			fatalf("Unexpected param `%s`. Run gg8 with --help for further information", arg)
+		}
+
+		indexOf := strings.Index(arg, "=")
+		if indexOf == -1 {
+			fatalf("Invalid argument `%s': Arguments must be declared as <key> = <value>", arg)
+		}
+		result.options = append(result.options, props.Pair{
+			K: arg[0:indexOf],
+			V: arg[indexOf+1:],
+		})
+	}
+
+	if scaffoldMode && result.scaffold == "" {
+		fatalf("Missing scaffold name. Run gg8 with --help for further information")
+	}
+	return result
+}
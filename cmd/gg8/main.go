@@ -1,16 +1,23 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"strings"
 
 	"github.com/manifoldco/promptui"
 
+	"github.com/gympass/go-giter8/fs"
 	"github.com/gympass/go-giter8/lexer"
 	"github.com/gympass/go-giter8/props"
 	"github.com/gympass/go-giter8/render"
@@ -33,11 +40,15 @@ func fatalf(format string, a ...interface{}) {
 	os.Exit(1)
 }
 
+// messages receives progress messages written by printf
+var messages io.Writer = os.Stdout
+
 func printf(format string, a ...interface{}) {
-	fmt.Printf("%s\n", fmt.Sprintf(format, a...))
+	_, _ = fmt.Fprintf(messages, "%s\n", fmt.Sprintf(format, a...))
 }
 
 var githubRepositoryRegexp = regexp.MustCompile(`(?i)^[a-z\d](?:[a-z\d]|-([a-z\d])){0,38}/[a-z0-9\-._]+$`)
+var archiveRegexp = regexp.MustCompile(`(?i)(\.zip|\.tar\.gz|\.tgz)$`)
 var helpRegexp = regexp.MustCompile(`(?i)^((-(-)?)?/?(help|usage))`)
 
 func usage() {
@@ -45,16 +56,56 @@ func usage() {
 		"gg8 (go-giter8) - giter8 alternative in Go",
 		"",
 		"Usage",
-		"gg8 REPOSITORY TARGET [-- [option=value]]",
+		"gg8 [flags] REPOSITORY TARGET [-- [option=value]]",
+		"gg8 [flags] --output-archive ARCHIVE REPOSITORY [-- [option=value]]",
+		"gg8 scaffold [flags] NAME [-- [option=value]]",
 		"",
 		"REPOSITORY - Either username/repo for GitHub repositories, or the",
 		"             full repository HTTPS/SSH path to clone",
 		"TARGET     - Directory to apply template to",
+		"NAME       - Scaffold to apply to the project in the current directory.",
+		"             Scaffolds are read from .g8. Answers saved when the",
+		"             project was generated provide options the scaffold",
+		"             does not declare.",
+		"",
+		"Flags",
+		"--answers FILE           - Loads answers from a .properties, .json or",
+		"                           .yaml file. gg8 will only ask for options",
+		"                           not present in FILE.",
+		"--dry-run[=FORMAT]       - Prints files that would be generated without",
+		"                           writing anything. FORMAT is either tree",
+		"                           (default) or json.",
+		"--format                 - Formats generated files: Go, JSON, Markdown,",
+		"                           text, shell, Makefile, TOML and .properties.",
+		"--no-input               - Do not ask for options. Fails in case any",
+		"                           option is not provided through --answers,",
+		"                           option=value, the user configuration or",
+		"                           environment variables.",
+		"--on-conflict POLICY     - Determines how to handle generated files that",
+		"                           already exist in TARGET: fail (default),",
+		"                           skip, overwrite, keep-both (writes the new",
+		"                           file with a .g8new suffix), or ask.",
+		"--output-archive ARCHIVE - Renders the template into a .zip, .tar.gz or",
+		"                           .tgz archive instead of a directory.",
+		"--verbose                - Lists every file as it is generated.",
 		"",
 		"Using option=value",
 		"When using option=value, gg8 will not ask for options, and will merge",
 		"all provided options into options provided by the repository, ",
 		"overwriting existing options.",
+		"",
+		"Option precedence",
+		"Options are taken from the following sources, from the lowest to the",
+		"highest precedence:",
+		"  - Default values provided by the template",
+		"  - User configuration, stored in gg8/defaults.properties within the",
+		"    user's configuration directory (e.g. ~/.config/gg8/defaults.properties)",
+		"  - Environment variables: option names in upper snake case, prefixed",
+		"    with G8_ (e.g. G8_ORGANIZATION for organization)",
+		"  - When applying scaffolds, answers saved in .g8-answers.properties",
+		"    for options the scaffold does not declare",
+		"  - The file provided through --answers",
+		"  - Options provided through option=value",
 	}
 
 	for _, s := range help {
@@ -65,9 +116,15 @@ func usage() {
 type TemplateMeta struct {
 	HasProperties bool
 	Root          string
+	// Scaffolds contains the path of the directory holding scaffolds shipped
+	// by the template, or an empty string in case it has none.
+	Scaffolds string
 }
 
-const propsFile = "default.properties"
+const (
+	propsFile   = "default.properties"
+	answersFile = ".g8-answers.properties"
+)
 
 func detectTemplateMeta(root string) (result TemplateMeta) {
 	s, err := os.Stat(path.Join(root, propsFile))
@@ -85,6 +142,12 @@ func detectTemplateMeta(root string) (result TemplateMeta) {
 		if err == nil && !s.IsDir() {
 			result.HasProperties = true
 		}
+
+		scaffolds := path.Join(root, "src", "main", "scaffolds")
+		s, err = os.Stat(scaffolds)
+		if err == nil && s.IsDir() {
+			result.Scaffolds = scaffolds
+		}
 	}
 	return
 }
@@ -104,98 +167,413 @@ func clone(gitPath, repo, target string) (bool, string) {
 	return true, ""
 }
 
-func main() {
-	hasGit, gitPath := findGit()
-	if !hasGit {
-		fatalf("Could not find `git' in your system. Please ensure it is installed and available through the PATH variable.")
-		os.Exit(1)
+// writeAnswers stores all properties used to render a template into the
+// answers file within out, so the project can be regenerated later using the
+// same inputs.
+func writeAnswers(out fs.Output, answers props.Pairs) error {
+	var buf bytes.Buffer
+	buf.WriteString("# Answers used by gg8 to generate this project\n")
+	if err := answers.WriteProperties(&buf); err != nil {
+		return err
 	}
+	return fs.WriteFile(out, answersFile, buf.Bytes(), 0644)
+}
 
-	if len(os.Args) == 0 {
-		usage()
-		os.Exit(1)
+// writeProjectFiles writes files describing how a project was generated
+// into out: the answers file, and scaffolds shipped by the template.
+func writeProjectFiles(out fs.Output, meta TemplateMeta, answers props.Pairs) error {
+	if err := writeAnswers(out, answers); err != nil {
+		return err
+	}
+	if meta.Scaffolds == "" {
+		return nil
 	}
+	return fs.CopyFS(out, render.ScaffoldsDir, os.DirFS(meta.Scaffolds))
+}
 
-	if len(os.Args) == 1 && helpRegexp.MatchString(os.Args[0]) {
-		usage()
-		os.Exit(0)
+// askConflict asks the user how to handle a generated file conflicting with
+// an existing one.
+func askConflict(path string) (render.ConflictPolicy, error) {
+	prompt := promptui.Select{
+		Label: fmt.Sprintf("%s already exists", path),
+		Items: []string{"skip", "overwrite", "keep-both", "fail"},
+	}
+	_, result, err := prompt.Run()
+	if err != nil {
+		return render.ConflictFail, err
 	}
+	return render.ParseConflictPolicy(result)
+}
 
-	repo := ""
-	target := ""
-	takingOpts := false
-	var options props.Pairs
+// renderArchive renders a template into an archive file, removing it in case
+// rendering fails.
+func renderArchive(ctx context.Context, currentProps props.Pairs, meta TemplateMeta, archivePath string, format fs.ArchiveFormat, opts *render.Options) error {
+	// Existing archives are only replaced when explicitly requested
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if opts.OnConflict == render.ConflictOverwrite {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	f, err := os.OpenFile(archivePath, flags, 0644)
+	if os.IsExist(err) {
+		return fmt.Errorf("%s already exists; use --on-conflict=overwrite to replace it", archivePath)
+	} else if err != nil {
+		return err
+	}
+
+	out := fs.NewArchiveOutput(f, format)
+	err = render.TemplateOutputContext(ctx, currentProps, fs.NewDirFS(meta.Root), out, opts)
+	if err == nil {
+		err = writeProjectFiles(out, meta, currentProps)
+	}
+	if err == nil {
+		err = out.Close()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(archivePath)
+	}
+	return err
+}
 
-	for i, arg := range os.Args {
-		if i == 0 {
+// printPlan writes a plan to stdout using a given format, either as a tree of
+// generated files or as JSON.
+func printPlan(plan render.Plan, format string) error {
+	if format == "json" {
+		if plan == nil {
+			plan = render.Plan{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(plan)
+	}
+
+	// Entries are indented according to their depth within the template, and
+	// named after their destination relative to their parent's, as a single
+	// segment may render into several ones, such as packaged names.
+	var skipped []string
+	dirs := map[string]string{".": ""}
+	for _, e := range plan {
+		if e.Action == render.ActionSkip {
+			skipped = append(skipped, e.Source)
 			continue
 		}
-		if arg == "--" {
-			if repo == "" {
-				fatalf("Found `--' before repository argument. Run gg8 with --help for further information")
-			}
-			if target == "" {
-				fatalf("Found `--' before destination argument. Run gg8 with --help for further information")
-			}
-			takingOpts = true
+		indent := strings.Repeat("  ", strings.Count(e.Source, "/"))
+		name := e.Destination
+		if parent := dirs[path.Dir(e.Source)]; parent != "" {
+			name = strings.TrimPrefix(name, parent+"/")
+		}
+		if e.Action == render.ActionDirectory {
+			dirs[e.Source] = e.Destination
+			fmt.Printf("%s%s/\n", indent, name)
+		} else {
+			fmt.Printf("%s%s (%s)\n", indent, name, e.Action)
+		}
+	}
+	for _, s := range skipped {
+		fmt.Printf("skipped: %s\n", s)
+	}
+	return nil
+}
+
+// renderDirectory renders a template into a target directory along with its
+// answers file and scaffolds. Nothing is written into target in case
+// rendering fails.
+func renderDirectory(ctx context.Context, currentProps props.Pairs, meta TemplateMeta, target string, opts *render.Options) error {
+	out, err := fs.NewStagedOutput(target)
+	if err != nil {
+		return err
+	}
+
+	err = render.TemplateOutputContext(ctx, currentProps, fs.NewDirFS(meta.Root), out, opts)
+	if err == nil {
+		err = writeProjectFiles(out, meta, currentProps)
+	}
+	if err != nil {
+		_ = out.Rollback()
+		return err
+	}
+	return out.Commit()
+}
+
+const (
+	defaultsSource = "template defaults"
+	envPrefix      = "G8_"
+)
+
+// userConfigFile returns the path of a properties file containing values
+// shared by all templates rendered by the current user.
+func userConfigFile() (string, bool) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", false
+	}
+	return filepath.Join(dir, "gg8", "defaults.properties"), true
+}
+
+// templateDefaults returns properties declared by a template along with their
+// default values, or nil in case it declares none.
+func templateDefaults(meta TemplateMeta) props.Pairs {
+	if !meta.HasProperties {
+		return nil
+	}
+	rawProps, err := os.ReadFile(path.Join(meta.Root, propsFile))
+	if err != nil {
+		fatalf("Error reading %s: %s", propsFile, err)
+	}
+	allProps, err := props.ParseProperties(string(rawProps))
+	if err != nil {
+		fatalf("Error parsing %s: %s", propsFile, err)
+	}
+	return allProps
+}
+
+// resolveProps computes the final set of properties used to render a
+// template. Values provided by sources are used as-is; remaining properties
+// declared by the template are prompted. Options provided through the
+// command line imply a non-interactive run, in which remaining properties use
+// their computed defaults, while --no-input requires all of them to be
+// answered by sources. Unless projectName is empty, it is used as the
+// default value of the `name' property, and is not required to be answered.
+func resolveProps(meta TemplateMeta, projectName string, sources props.Layers, args cliArgs) props.Pairs {
+	// Without properties there is nothing to ask for
+	interactive := !args.noInput && len(args.options) == 0 && meta.HasProperties
+	allProps := templateDefaults(meta)
+	if projectName != "" {
+		allProps.Merge(props.Pairs{{K: "name", V: projectName}})
+	}
+
+	layers := append(props.Layers{props.NewStaticSource(defaultsSource, allProps)}, sources...)
+	resolved, err := layers.Resolve()
+	if err != nil {
+		fatalf("Error loading answers: %s", err)
+	}
+
+	resolver, err := props.NewResolver(allProps, func(ast lexer.AST, values props.Pairs) (string, error) {
+		return render.NewExecutor(values).Exec(ast)
+	})
+	if err != nil {
+		fatalf("Error processing %s: %s", propsFile, err)
+	}
+
+	if interactive {
+		printf("Preparing template:")
+	}
+
+	for _, p := range resolved.Values.Pairs() {
+		origin, _ := resolved.Origin(p.K)
+		if origin == defaultsSource {
 			continue
 		}
-		if repo == "" {
-			if githubRepositoryRegexp.MatchString(arg) {
-				suffix := ""
-				if !strings.HasSuffix(arg, ".git") {
-					suffix = ".git"
-				}
-				repo = fmt.Sprintf("https://github.com/%s%s", arg, suffix)
-			} else {
-				repo = arg
-			}
+		resolver.Set(p.K, p.V)
+		if _, declared := allProps.Fetch(p.K); declared {
+			printf("%s: provided by %s", p.K, origin)
+		}
+	}
+
+	var missing []string
+	for _, key := range resolver.Order() {
+		if resolver.Answered(key) {
+			continue
+		}
+		if args.noInput && !(key == "name" && projectName != "") {
+			missing = append(missing, key)
 			continue
 		}
 
-		if !takingOpts && repo != "" {
-			target = arg
+		computedValue, err := resolver.Default(key)
+		if err != nil {
+			if !interactive {
+				fatalf("Property %s has no answer, and its default could not be computed: %s", key, err)
+			}
+			fatalf("%s", err)
+		}
+		if !interactive {
 			continue
 		}
 
-		if !takingOpts && repo != "" && target != "" {
-			fatalf("Unexpected param `%s`. Run gg8 with --help for further information", arg)
+		prompt := promptui.Prompt{
+			Default: computedValue,
+			Label:   key,
+		}
+		promptResult, err := prompt.Run()
+		if err != nil {
+			fatalf("Error executing prompt: %s", err)
+		}
+		resolver.Set(key, promptResult)
+	}
+	if len(missing) > 0 {
+		fatalf("Missing answers for %s. Provide them through --answers or option=value, or run gg8 without --no-input", strings.Join(missing, ", "))
+	}
+
+	currentProps, err := resolver.Resolve()
+	if err != nil {
+		fatalf("%s", err)
+	}
+	return currentProps
+}
+
+// renderOptions validates flags affecting rendering, returning the
+// render.Options they describe.
+func renderOptions(args cliArgs) *render.Options {
+	if args.dryRun != "" && args.dryRun != "tree" && args.dryRun != "json" {
+		fatalf("Invalid value for --dry-run: `%s'. Use either tree or json", args.dryRun)
+	}
+	if args.dryRun == "json" {
+		// Keep stdout parseable
+		messages = os.Stderr
+	}
+
+	renderOpts := &render.Options{ConflictHandler: askConflict, Workers: runtime.NumCPU()}
+	if args.onConflict != "" {
+		policy, err := render.ParseConflictPolicy(args.onConflict)
+		if err != nil {
+			fatalf("Invalid value for --on-conflict: %s", err)
+		}
+		if policy == render.ConflictAsk && args.noInput {
+			fatalf("--on-conflict=ask cannot be used along with --no-input")
+		}
+		renderOpts.OnConflict = policy
+	}
+	if args.format {
+		renderOpts.Formatters = render.DefaultFormatters()
+	}
+	if args.verbose {
+		renderOpts.OnEvent = verboseReporter
+	} else if isTerminal(os.Stdout) && renderOpts.OnConflict != render.ConflictAsk {
+		// Prompts would be drawn over the progress bar
+		renderOpts.OnEvent = progressReporter(os.Stdout)
+	}
+	return renderOpts
+}
+
+// propSources returns sources for option values, ordered by precedence.
+// extra sources are placed between environment variables and the file
+// provided through --answers.
+func propSources(args cliArgs, extra ...props.Source) props.Layers {
+	var sources props.Layers
+	if configFile, ok := userConfigFile(); ok {
+		sources = append(sources, props.NewFileSource(configFile, true))
+	}
+	sources = append(sources, props.NewEnvSource(envPrefix))
+	sources = append(sources, extra...)
+	if args.answersPath != "" {
+		sources = append(sources, props.NewFileSource(args.answersPath, false))
+	}
+	return append(sources, props.NewStaticSource("command line", args.options))
+}
+
+// runScaffold renders a scaffold into the project within the current
+// directory. Answers saved when the project was generated provide properties
+// the scaffold does not declare.
+func runScaffold(args cliArgs, renderOpts *render.Options) {
+	project, err := os.Getwd()
+	if err != nil {
+		fatalf("Error determining current directory: %s", err)
+	}
+
+	root, err := render.ScaffoldPath(project, args.scaffold)
+	if err != nil {
+		names, _ := render.Scaffolds(project)
+		if len(names) == 0 {
+			fatalf("Error: %s. No scaffolds are available in %s", err, render.ScaffoldsDir)
+		}
+		fatalf("Error: %s. Available scaffolds: %s", err, strings.Join(names, ", "))
+	}
+
+	meta := TemplateMeta{Root: root}
+	if s, err := os.Stat(filepath.Join(root, propsFile)); err == nil && !s.IsDir() {
+		meta.HasProperties = true
+	}
+
+	// Saved answers only provide properties the scaffold does not declare,
+	// such as the project's package; declared ones are asked for as usual.
+	saved, err := props.ReadFile(filepath.Join(project, answersFile))
+	if err != nil && !os.IsNotExist(err) {
+		fatalf("Error reading %s: %s", answersFile, err)
+	}
+	declared := templateDefaults(meta)
+	var inherited props.Pairs
+	for _, p := range saved {
+		if _, ok := declared.Fetch(p.K); !ok {
+			inherited = append(inherited, p)
 		}
+	}
 
-		indexOf := strings.Index(arg, "=")
-		if indexOf == -1 {
-			fatalf("Invalid argument `%s': Arguments must be declared as <key> = <value>", arg)
+	sources := propSources(args, props.NewStaticSource(answersFile, inherited))
+	currentProps := resolveProps(meta, "", sources, args)
+
+	if args.dryRun != "" {
+		plan, err := render.PlanFS(currentProps, fs.NewDirFS(root), renderOpts)
+		if err != nil {
+			fatalf("Error planning scaffold: %s", err)
 		}
-		options = append(options, props.Pair{
-			K: arg[0:indexOf],
-			V: arg[indexOf+1:],
-		})
+		if err = printPlan(plan, args.dryRun); err != nil {
+			fatalf("Error printing plan: %s", err)
+		}
+		return
 	}
 
-	if repo == "" {
+	printf("\nApplying scaffold %s to %s", args.scaffold, project)
+	if err = render.ScaffoldDirectory(currentProps, project, args.scaffold, renderOpts); err != nil {
+		fatalf("Error rendering scaffold: %s", err)
+	}
+}
+
+func main() {
+	if len(os.Args) <= 1 {
 		usage()
 		os.Exit(1)
 	}
 
-	if target == "" {
+	args := parseArgs(os.Args[1:])
+	if args.scaffold != "" {
+		runScaffold(args, renderOptions(args))
+		return
+	}
+	if args.repo == "" || (args.target == "" && args.archivePath == "") {
 		usage()
 		os.Exit(1)
 	}
 
-	if newTarget, err := filepath.Abs(target); err != nil {
-		fatalf("Error calculating absolute path for `%s': %s", target, err)
+	hasGit, gitPath := findGit()
+	if !hasGit {
+		fatalf("Could not find `git' in your system. Please ensure it is installed and available through the PATH variable.")
+		os.Exit(1)
+	}
+
+	renderOpts := renderOptions(args)
+
+	var target, projectName string
+	var archiveFormat fs.ArchiveFormat
+	if args.archivePath != "" {
+		if args.target != "" {
+			fatalf("TARGET cannot be used along with --output-archive. Run gg8 with --help for further information")
+		}
+		var ok bool
+		if archiveFormat, ok = fs.ArchiveFormatFor(args.archivePath); !ok {
+			fatalf("Unsupported archive format for `%s': use .zip, .tar.gz or .tgz", args.archivePath)
+		}
+		projectName = archiveRegexp.ReplaceAllString(filepath.Base(args.archivePath), "")
 	} else {
-		target = newTarget
+		var err error
+		if target, err = filepath.Abs(args.target); err != nil {
+			fatalf("Error calculating absolute path for `%s': %s", args.target, err)
+		}
+		projectName = filepath.Base(target)
 	}
 
+	sources := propSources(args)
+
 	// create clone destination
 	cloneDir, err := os.MkdirTemp("", "gg8")
 	if err != nil {
 		fatalf("Error creating temporary directory: %s", err)
 	}
 
-	printf("Cloning %s...", repo)
-	ok, errStr := clone(gitPath, repo, cloneDir)
+	printf("Cloning %s...", args.repo)
+	ok, errStr := clone(gitPath, args.repo, cloneDir)
 	if !ok {
 		fatalf("%s", errStr)
 	}
@@ -205,48 +583,33 @@ func main() {
 	}
 
 	templateMeta := detectTemplateMeta(cloneDir)
-	var currentProps = props.Pairs{{K: "name", V: filepath.Base(target)}}
+	currentProps := resolveProps(templateMeta, projectName, sources, args)
 
-	if templateMeta.HasProperties && len(options) == 0 {
-		printf("Preparing template:")
-
-		rawProps, err := os.ReadFile(path.Join(templateMeta.Root, propsFile))
+	if args.dryRun != "" {
+		plan, err := render.PlanFS(currentProps, fs.NewDirFS(templateMeta.Root), renderOpts)
 		if err != nil {
-			fatalf("Error reading %s: %s", propsFile, err)
+			fatalf("Error planning template: %s", err)
 		}
-
-		allProps, err := props.ParseProperties(string(rawProps))
-		if err != nil {
-			fatalf("Error parsing %s: %s", propsFile, err)
+		if err = printPlan(plan, args.dryRun); err != nil {
+			fatalf("Error printing plan: %s", err)
 		}
-		allProps.Merge(currentProps)
+		return
+	}
 
-		for _, p := range allProps {
-			r := render.NewExecutor(currentProps)
-			propAST, err := lexer.Tokenize(p.V)
-			if err != nil {
-				fatalf("Error parsing property %s: %s", p.K, err)
-			}
-			computedValue, err := r.Exec(propAST)
-			if err != nil {
-				fatalf("Error populating property %s: %s", p.K, err)
-			}
-			prompt := promptui.Prompt{
-				Default: computedValue,
-				Label:   p.K,
-			}
-			promptResult, err := prompt.Run()
-			if err != nil {
-				fatalf("Error executing prompt: %s", err)
-			}
-			currentProps = append(currentProps, props.Pair{K: p.K, V: promptResult})
+	// Interrupting gg8 while rendering discards partial results
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if args.archivePath != "" {
+		printf("\nRendering template to %s", args.archivePath)
+		if err = renderArchive(ctx, currentProps, templateMeta, args.archivePath, archiveFormat, renderOpts); err != nil {
+			fatalf("Error rendering template archive: %s", err)
 		}
-	} else if templateMeta.HasProperties && len(options) != 1 {
-		currentProps = options
+		return
 	}
+
 	printf("\nRendering template to %s", target)
-	err = render.TemplateDirectory(currentProps, templateMeta.Root, target)
-	if err != nil {
+	if err = renderDirectory(ctx, currentProps, templateMeta, target, renderOpts); err != nil {
 		fatalf("Error rendering directory template: %s", err)
 	}
 }
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gympass/go-giter8/render"
+)
+
+const progressWidth = 30
+
+// isTerminal returns whether f refers to a character device, such as a
+// terminal, where progress can be redrawn in place.
+func isTerminal(f *os.File) bool {
+	stat, err := f.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
+}
+
+// verboseReporter logs every item written while rendering a template
+func verboseReporter(e render.Event) {
+	switch e.Kind {
+	case render.EventWriting:
+		return
+	case render.EventSkipped:
+		if e.Destination == "" {
+			printf("  skipped   %s", e.Source)
+			return
+		}
+	case render.EventError:
+		printf("  error     %s: %s", e.Source, e.Err)
+		return
+	}
+	printf("  %-9s %s", e.Kind, e.Destination)
+}
+
+// progressReporter returns an event handler drawing a progress bar into f
+func progressReporter(f *os.File) render.EventHandler {
+	return func(e render.Event) {
+		if e.Kind == render.EventWriting || e.Total == 0 {
+			return
+		}
+		filled := progressWidth * e.Done / e.Total
+		_, _ = fmt.Fprintf(f, "\r[%s%s] %d/%d", strings.Repeat("#", filled), strings.Repeat(" ", progressWidth-filled), e.Done, e.Total)
+		if e.Kind == render.EventError || e.Done == e.Total {
+			_, _ = fmt.Fprintln(f)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package fs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ArchiveFormat represents a kind of archive supported by ArchiveOutput
+type ArchiveFormat int
+
+const (
+	ArchiveZip ArchiveFormat = iota
+	ArchiveTarGz
+)
+
+// ArchiveFormatFor returns the ArchiveFormat matching the extension of a
+// given file name, and true. Returns false in case the extension is not
+// supported; supported extensions are `.zip', `.tar.gz' and `.tgz'.
+func ArchiveFormatFor(name string) (ArchiveFormat, bool) {
+	name = strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return ArchiveZip, true
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return ArchiveTarGz, true
+	}
+	return 0, false
+}
+
+type archiveWriter interface {
+	writeDir(info memFileInfo) error
+	writeFile(info memFileInfo, data []byte) error
+	writeSymlink(info memFileInfo, target string) error
+	close() error
+}
+
+type zipArchiveWriter struct {
+	w *zip.Writer
+}
+
+func (z zipArchiveWriter) writeDir(info memFileInfo) error {
+	header := &zip.FileHeader{Name: info.name + "/", Modified: info.modTime}
+	header.SetMode(info.mode)
+	_, err := z.w.CreateHeader(header)
+	return err
+}
+
+func (z zipArchiveWriter) writeFile(info memFileInfo, data []byte) error {
+	header := &zip.FileHeader{Name: info.name, Method: zip.Deflate, Modified: info.modTime}
+	header.SetMode(info.mode)
+	w, err := z.w.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func (z zipArchiveWriter) writeSymlink(info memFileInfo, target string) error {
+	header := &zip.FileHeader{Name: info.name, Method: zip.Store, Modified: info.modTime}
+	header.SetMode(info.mode)
+	w, err := z.w.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, target)
+	return err
+}
+
+func (z zipArchiveWriter) close() error {
+	return z.w.Close()
+}
+
+type tarGzArchiveWriter struct {
+	gz *gzip.Writer
+	w  *tar.Writer
+}
+
+func (t tarGzArchiveWriter) writeDir(info memFileInfo) error {
+	return t.w.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     info.name + "/",
+		Mode:     int64(info.mode.Perm()),
+		ModTime:  info.modTime,
+	})
+}
+
+func (t tarGzArchiveWriter) writeFile(info memFileInfo, data []byte) error {
+	err := t.w.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     info.name,
+		Mode:     int64(info.mode.Perm()),
+		Size:     int64(len(data)),
+		ModTime:  info.modTime,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = t.w.Write(data)
+	return err
+}
+
+func (t tarGzArchiveWriter) writeSymlink(info memFileInfo, target string) error {
+	return t.w.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeSymlink,
+		Name:     info.name,
+		Linkname: target,
+		Mode:     int64(info.mode.Perm()),
+		ModTime:  info.modTime,
+	})
+}
+
+func (t tarGzArchiveWriter) close() error {
+	if err := t.w.Close(); err != nil {
+		return err
+	}
+	return t.gz.Close()
+}
+
+// ArchiveOutput is an Output writing all files and directories into an
+// archive stream, preserving their modes. Entries are written as soon as
+// they are complete; Close must be called to finish the archive.
+type ArchiveOutput struct {
+	mu      sync.Mutex
+	entries map[string]memFileInfo
+	w       archiveWriter
+}
+
+// NewArchiveOutput returns a new ArchiveOutput writing an archive with a
+// given format into w.
+func NewArchiveOutput(w io.Writer, format ArchiveFormat) *ArchiveOutput {
+	var aw archiveWriter
+	switch format {
+	case ArchiveTarGz:
+		gz := gzip.NewWriter(w)
+		aw = tarGzArchiveWriter{gz: gz, w: tar.NewWriter(gz)}
+	default:
+		aw = zipArchiveWriter{w: zip.NewWriter(w)}
+	}
+	return &ArchiveOutput{entries: map[string]memFileInfo{}, w: aw}
+}
+
+func (a *ArchiveOutput) mkdirAll(name string, perm os.FileMode) error {
+	if name == "." {
+		return nil
+	}
+	if e, ok := a.entries[name]; ok {
+		if !e.IsDir() {
+			return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+		}
+		return nil
+	}
+	if err := a.mkdirAll(path.Dir(name), perm); err != nil {
+		return err
+	}
+	info := memFileInfo{name: name, mode: os.ModeDir | perm.Perm(), modTime: time.Now()}
+	if err := a.w.writeDir(info); err != nil {
+		return err
+	}
+	a.entries[name] = info
+	return nil
+}
+
+// MkdirAll implements Output
+func (a *ArchiveOutput) MkdirAll(name string, perm os.FileMode) error {
+	if err := validateName("mkdir", name); err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.mkdirAll(name, perm)
+}
+
+type archiveEntryWriter struct {
+	bytes.Buffer
+	out  *ArchiveOutput
+	name string
+	perm os.FileMode
+}
+
+func (w *archiveEntryWriter) Close() error {
+	w.out.mu.Lock()
+	defer w.out.mu.Unlock()
+	if _, ok := w.out.entries[w.name]; ok {
+		return &fs.PathError{Op: "create", Path: w.name, Err: fs.ErrExist}
+	}
+	if err := w.out.mkdirAll(path.Dir(w.name), 0755); err != nil {
+		return err
+	}
+	info := memFileInfo{name: w.name, size: int64(w.Len()), mode: w.perm, modTime: time.Now()}
+	if err := w.out.w.writeFile(info, w.Bytes()); err != nil {
+		return err
+	}
+	w.out.entries[w.name] = info
+	return nil
+}
+
+// Create implements Output. As archive entries cannot be replaced once
+// written, closing the returned writer fails in case the archive already
+// contains an entry with the same name. Parent directories are created as
+// needed.
+func (a *ArchiveOutput) Create(name string, perm os.FileMode) (io.WriteCloser, error) {
+	if err := validateName("create", name); err != nil {
+		return nil, err
+	}
+	return &archiveEntryWriter{out: a, name: name, perm: perm}, nil
+}
+
+// Symlink implements SymlinkOutput. As archive entries cannot be replaced
+// once written, it fails in case the archive already contains an entry with
+// the same name.
+func (a *ArchiveOutput) Symlink(target, name string) error {
+	if err := validateName("symlink", name); err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, ok := a.entries[name]; ok {
+		return &fs.PathError{Op: "symlink", Path: name, Err: fs.ErrExist}
+	}
+	if err := a.mkdirAll(path.Dir(name), 0755); err != nil {
+		return err
+	}
+	info := memFileInfo{name: name, size: int64(len(target)), mode: os.ModeSymlink | os.ModePerm, modTime: time.Now()}
+	if err := a.w.writeSymlink(info, target); err != nil {
+		return err
+	}
+	a.entries[name] = info
+	return nil
+}
+
+// Stat implements Output, describing entries already written to the archive.
+func (a *ArchiveOutput) Stat(name string) (os.FileInfo, error) {
+	if err := validateName("stat", name); err != nil {
+		return nil, err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	e, ok := a.entries[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return e, nil
+}
+
+// Close finishes writing the archive. It does not close the underlying
+// writer.
+func (a *ArchiveOutput) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.w.close()
+}
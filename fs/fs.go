@@ -1,7 +1,11 @@
 package fs
 
 import (
+	"errors"
+	"fmt"
 	"io/fs"
+	"os"
+	"path"
 	"path/filepath"
 	"strings"
 
@@ -15,45 +19,246 @@ type Node struct {
 type TreeItem struct {
 	Source string
 	IsDir  bool
-	Nodes  []Node
+	// Symlink indicates whether the item is a symbolic link kept as such,
+	// which happens unless ScanOptions.Symlinks is SymlinkFollow.
+	Symlink bool
+	Nodes   []Node
 }
 
 func prepareNodeName(rawName string) lexer.AST {
 	ast, err := lexer.Tokenize(rawName)
 	if err != nil {
-		return lexer.AST{lexer.Literal{String: rawName}}
+		return lexer.AST{&lexer.Literal{String: rawName}}
 	}
 	return ast
 }
 
-// ScanTree takes a source directory and returns a slice of TreeItem
-// ready to be processed by a renderer
-func ScanTree(source string) ([]TreeItem, error) {
-	var items []TreeItem
-	sep := string(filepath.Separator)
-	err := filepath.Walk(source, func(path string, info fs.FileInfo, err error) error {
-		if err != nil {
-			return err
+// IgnoreFile is the name of a file, at the root of a template, listing
+// patterns of files and directories that are not part of the generated
+// output. Patterns follow the semantics described by Glob.
+const IgnoreFile = ".g8ignore"
+
+// ScanOptions determines how a template is scanned
+type ScanOptions struct {
+	// Symlinks determines how symbolic links are handled. Under
+	// SymlinkFollow, symbolic links are scanned as the files or directories
+	// they point to; otherwise, they are returned as items with Symlink set,
+	// and never descended into.
+	Symlinks SymlinkPolicy
+	// Exclude contains patterns of files and directories to be skipped, in
+	// addition to the ones listed by the template's IgnoreFile. Patterns are
+	// evaluated after the ones from IgnoreFile, so negated patterns may
+	// include files it excludes.
+	Exclude GlobSet
+}
+
+// ReadIgnoreFile reads patterns listed by the IgnoreFile at the root of
+// fsys. Returns no patterns in case the file does not exist.
+func ReadIgnoreFile(fsys fs.FS) (GlobSet, error) {
+	data, err := fs.ReadFile(fsys, IgnoreFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	patterns, err := CompileGlobSet(strings.Split(string(data), "\n"))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s: %s", IgnoreFile, err)
+	}
+	return patterns, nil
+}
+
+// ScanFS takes a file system containing a template and returns a slice of
+// TreeItem ready to be processed by a renderer. The Source of each item is
+// its path within fsys. Calling this function is the same as calling
+// ScanFSOpts without options.
+func ScanFS(fsys fs.FS) ([]TreeItem, error) {
+	return ScanFSOpts(fsys, nil)
+}
+
+// ScanFSOpts works like ScanFS, using an optional ScanOptions structure.
+// Files and directories matching the template's IgnoreFile or
+// opts.Exclude are skipped entirely, along with the IgnoreFile itself.
+// Returns an error in case following symbolic links leads to a loop, or to a
+// file outside of fsys. Following symbolic links requires fsys to implement
+// ReadLinkFS.
+func ScanFSOpts(fsys fs.FS, opts *ScanOptions) ([]TreeItem, error) {
+	s := &scanner{fsys: fsys}
+	if opts != nil {
+		s.opts = *opts
+	}
+	exclude, err := ReadIgnoreFile(fsys)
+	if err != nil {
+		return nil, err
+	}
+	s.exclude = append(exclude, s.opts.Exclude...)
+
+	root, err := fs.Stat(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	if err = s.scan(".", []fs.FileInfo{root}); err != nil {
+		return nil, err
+	}
+	return s.items, nil
+}
+
+// maxScanDepth limits how deep directories are scanned, preventing loops
+// through symbolic links from going on forever in file systems whose
+// fs.FileInfo cannot be compared through os.SameFile.
+const maxScanDepth = 255
+
+type scanner struct {
+	fsys    fs.FS
+	opts    ScanOptions
+	exclude GlobSet
+	items   []TreeItem
+}
+
+// scan appends all items within dir to s.items, in lexical order, with
+// directories preceding their contents. ancestors describes dir and all its
+// parents, and is used to detect loops.
+func (s *scanner) scan(dir string, ancestors []fs.FileInfo) error {
+	entries, err := fs.ReadDir(s.fsys, dir)
+	if err != nil {
+		return err
+	}
+	for _, d := range entries {
+		p := path.Join(dir, d.Name())
+		if p == IgnoreFile || strings.EqualFold("default.properties", p) {
+			continue
 		}
-		if path == source || strings.EqualFold(filepath.Join(source, "default.properties"), path) {
-			return nil
+
+		// Excluded links are never followed. Like git, directory-only
+		// patterns do not match the links themselves, only the directories
+		// they lead to.
+		var info fs.FileInfo
+		isDir, symlink := d.IsDir(), d.Type()&fs.ModeSymlink != 0
+		if s.exclude.Match(p, isDir) {
+			continue
+		}
+		if symlink && s.opts.Symlinks == SymlinkFollow {
+			if info, err = s.follow(p); err != nil {
+				return err
+			}
+			isDir, symlink = info.IsDir(), false
+			if isDir && s.exclude.Match(p, isDir) {
+				continue
+			}
 		}
 
 		var nodes []Node
-		src := strings.Split(strings.TrimPrefix(path, source+sep), sep)
-		for _, x := range src {
+		for _, x := range strings.Split(p, "/") {
 			nodes = append(nodes, Node{Name: prepareNodeName(x)})
 		}
-		items = append(items, TreeItem{
-			Source: path,
-			IsDir:  info.IsDir(),
-			Nodes:  nodes,
+		s.items = append(s.items, TreeItem{
+			Source:  p,
+			IsDir:   isDir,
+			Symlink: symlink,
+			Nodes:   nodes,
 		})
+		if !isDir {
+			continue
+		}
+
+		if info == nil {
+			if info, err = d.Info(); err != nil {
+				return err
+			}
+		}
+		for _, a := range ancestors {
+			if os.SameFile(a, info) {
+				return fmt.Errorf("symlink loop detected at %s", p)
+			}
+		}
+		if len(ancestors) > maxScanDepth {
+			return fmt.Errorf("maximum directory depth exceeded at %s", p)
+		}
+		if err = s.scan(p, append(ancestors, info)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// maxSymlinkHops limits how many symbolic links are followed while resolving
+// a single path, as done by most operating systems.
+const maxSymlinkHops = 40
+
+// follow returns a fs.FileInfo describing the file the symbolic link at p
+// points to. Links pointing outside of the template are rejected, so that
+// rendering never copies unrelated files, such as the user's home directory.
+func (s *scanner) follow(p string) (fs.FileInfo, error) {
+	l, ok := s.fsys.(ReadLinkFS)
+	if !ok {
+		return nil, fmt.Errorf("cannot follow symlink %s: file system does not support symbolic links", p)
+	}
+	target, err := resolveLink(l, p)
+	if err == errOutsideRoot {
+		return nil, fmt.Errorf("symlink %s points outside of the template", p)
+	} else if err != nil {
+		return nil, fmt.Errorf("error following symlink %s: %s", p, err)
+	}
+	return fs.Stat(s.fsys, target)
+}
 
-		return nil
-	})
+var errOutsideRoot = errors.New("path is outside of the root")
+
+// resolveLink returns the path name refers to within fsys once all symbolic
+// links are resolved, one component at a time. Returns errOutsideRoot in
+// case any link is absolute or leads out of the root.
+func resolveLink(fsys ReadLinkFS, name string) (string, error) {
+	resolved := "."
+	pending := strings.Split(name, "/")
+	hops := 0
+	for len(pending) > 0 {
+		c := pending[0]
+		pending = pending[1:]
+		switch c {
+		case "", ".":
+			continue
+		case "..":
+			if resolved == "." {
+				return "", errOutsideRoot
+			}
+			resolved = path.Dir(resolved)
+			continue
+		}
+
+		current := path.Join(resolved, c)
+		info, err := fsys.Lstat(current)
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&fs.ModeSymlink == 0 {
+			resolved = current
+			continue
+		}
+		if hops++; hops > maxSymlinkHops {
+			return "", fmt.Errorf("too many levels of symbolic links")
+		}
+		target, err := fsys.ReadLink(current)
+		if err != nil {
+			return "", err
+		}
+		if path.IsAbs(target) || filepath.IsAbs(filepath.FromSlash(target)) || filepath.VolumeName(filepath.FromSlash(target)) != "" {
+			return "", errOutsideRoot
+		}
+		pending = append(strings.Split(target, "/"), pending...)
+	}
+	return resolved, nil
+}
+
+// ScanTree takes a source directory and returns a slice of TreeItem
+// ready to be processed by a renderer. The Source of each item is its path
+// within the operating system's file system.
+func ScanTree(source string) ([]TreeItem, error) {
+	items, err := ScanFS(NewDirFS(source))
 	if err != nil {
 		return nil, err
 	}
+	for i, item := range items {
+		items[i].Source = filepath.Join(source, filepath.FromSlash(item.Source))
+	}
 	return items, nil
 }
@@ -0,0 +1,44 @@
+package fs
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sources(items []TreeItem) []string {
+	var result []string
+	for _, i := range items {
+		result = append(result, i.Source)
+	}
+	return result
+}
+
+func TestScanFSIgnore(t *testing.T) {
+	fsys := fstest.MapFS{
+		IgnoreFile:                   {Data: []byte("# Template-only files\n/README.md\n.github/\n*.log\n!keep.log\n")},
+		"default.properties":         {Data: []byte("name=foo\n")},
+		"README.md":                  {Data: []byte("About this template\n")},
+		".github/workflows/test.yml": {Data: []byte("on: push\n")},
+		"$name$/README.md":           {Data: []byte("# $name$\n")},
+		"$name$/debug.log":           {Data: []byte("\n")},
+		"$name$/keep.log":            {Data: []byte("\n")},
+		"$name$/test/fixture.txt":    {Data: []byte("\n")},
+	}
+
+	items, err := ScanFS(fsys)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"$name$", "$name$/README.md", "$name$/keep.log", "$name$/test", "$name$/test/fixture.txt"}, sources(items))
+
+	exclude, err := CompileGlobSet([]string{"test/", "!debug.log"})
+	require.NoError(t, err)
+	items, err = ScanFSOpts(fsys, &ScanOptions{Exclude: exclude})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"$name$", "$name$/README.md", "$name$/debug.log", "$name$/keep.log"}, sources(items))
+
+	fsys[IgnoreFile] = &fstest.MapFile{Data: []byte("[oops\n")}
+	_, err = ScanFS(fsys)
+	assert.EqualError(t, err, "error parsing .g8ignore: invalid pattern `[oops': unterminated character class")
+}
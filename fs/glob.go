@@ -0,0 +1,275 @@
+package fs
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Glob is a compiled pattern following .gitignore semantics:
+//
+//   - `*' matches any sequence of characters except `/', `?' matches any
+//     single character except `/', and `[...]' matches a character class,
+//     which is negated when starting with `!' or `^';
+//   - `**/' at the beginning of a pattern matches in all directories, `/**'
+//     at its end matches everything inside a directory, and `/**/' matches
+//     zero or more directories;
+//   - patterns containing a `/' at their beginning or middle are anchored to
+//     the root, while other patterns match at any level;
+//   - patterns ending with `/' only match directories;
+//   - patterns starting with `!' are negated;
+//   - a backslash escapes the character following it.
+//
+// Names matched against a Glob are slash-separated paths relative to the
+// root the pattern applies to.
+type Glob struct {
+	pattern string
+	negate  bool
+	dirOnly bool
+	re      *regexp.Regexp
+}
+
+// CompileGlob parses a pattern, returning an error in case it is invalid.
+func CompileGlob(pattern string) (*Glob, error) {
+	g := &Glob{pattern: pattern}
+	p := trimTrailingSpaces(strings.TrimRight(pattern, "\r"))
+	if strings.HasPrefix(p, "!") {
+		g.negate = true
+		p = p[1:]
+	}
+	if strings.HasSuffix(p, "/") && !strings.HasSuffix(p, `\/`) {
+		g.dirOnly = true
+		p = strings.TrimRight(p, "/")
+	}
+	if p == "" {
+		return nil, fmt.Errorf("invalid pattern `%s': pattern is empty", pattern)
+	}
+
+	var b strings.Builder
+	if strings.Contains(p, "/") {
+		p = strings.TrimPrefix(p, "/")
+		b.WriteString("^")
+	} else {
+		b.WriteString("^(?:.*/)?")
+	}
+	if err := translateGlob(&b, p); err != nil {
+		return nil, fmt.Errorf("invalid pattern `%s': %s", pattern, err)
+	}
+	b.WriteString("$")
+
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern `%s': %s", pattern, err)
+	}
+	g.re = re
+	return g, nil
+}
+
+// MustCompileGlob is like CompileGlob, but panics in case the pattern is
+// invalid.
+func MustCompileGlob(pattern string) *Glob {
+	g, err := CompileGlob(pattern)
+	if err != nil {
+		panic(err)
+	}
+	return g
+}
+
+// trimTrailingSpaces removes trailing spaces not escaped by a backslash
+func trimTrailingSpaces(p string) string {
+	end := len(p)
+	for end > 0 && p[end-1] == ' ' {
+		backslashes := 0
+		for i := end - 2; i >= 0 && p[i] == '\\'; i-- {
+			backslashes++
+		}
+		if backslashes%2 == 1 {
+			break
+		}
+		end--
+	}
+	return p[:end]
+}
+
+// translateGlob writes a regular expression equivalent to a glob pattern into
+// b.
+func translateGlob(b *strings.Builder, p string) error {
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		switch c {
+		case '*':
+			start := i
+			for i+1 < len(p) && p[i+1] == '*' {
+				i++
+			}
+			segmentStart := start == 0 || p[start-1] == '/'
+			segmentEnd := i+1 == len(p) || p[i+1] == '/'
+			switch {
+			case i > start && segmentStart && i+1 == len(p):
+				b.WriteString(".*")
+			case i > start && segmentStart && segmentEnd:
+				b.WriteString("(?:.*/)?")
+				i++
+			default:
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			n, err := translateClass(b, p[i:])
+			if err != nil {
+				return err
+			}
+			i += n - 1
+		case '\\':
+			if i+1 == len(p) {
+				return fmt.Errorf("trailing backslash")
+			}
+			i++
+			b.WriteString(regexp.QuoteMeta(p[i : i+1]))
+		default:
+			b.WriteString(regexp.QuoteMeta(p[i : i+1]))
+		}
+	}
+	return nil
+}
+
+// translateClass writes a regular expression equivalent to the character
+// class at the beginning of p into b, returning how many bytes of p it
+// comprises.
+func translateClass(b *strings.Builder, p string) (int, error) {
+	i := 1
+	var class strings.Builder
+	class.WriteString("[")
+	if i < len(p) && (p[i] == '!' || p[i] == '^') {
+		class.WriteString("^/")
+		i++
+	}
+	empty := true
+	for ; i < len(p); i++ {
+		c, escaped := p[i], false
+		if c == ']' && !empty {
+			class.WriteString("]")
+			b.WriteString(class.String())
+			return i + 1, nil
+		}
+		if c == '\\' {
+			if i+1 == len(p) {
+				return 0, fmt.Errorf("trailing backslash")
+			}
+			i++
+			c, escaped = p[i], true
+		}
+		switch {
+		case c == '/':
+			return 0, fmt.Errorf("character class cannot match `/'")
+		case c == '-' && !escaped && !empty && i+1 < len(p) && p[i+1] != ']':
+			class.WriteByte('-')
+		case c == '-':
+			class.WriteString(`\-`)
+		default:
+			class.WriteString(regexp.QuoteMeta(p[i : i+1]))
+		}
+		empty = false
+	}
+	return 0, fmt.Errorf("unterminated character class")
+}
+
+// String returns the pattern the Glob was compiled from
+func (g *Glob) String() string {
+	return g.pattern
+}
+
+// Negated indicates whether the pattern starts with `!'
+func (g *Glob) Negated() bool {
+	return g.negate
+}
+
+// matchName indicates whether the pattern matches a given name, without
+// taking its parent directories into account.
+func (g *Glob) matchName(name string, isDir bool) bool {
+	if g.dirOnly && !isDir {
+		return false
+	}
+	return g.re.MatchString(name)
+}
+
+// Match indicates whether the pattern matches a given name, or any of its
+// parent directories. Negation is ignored; use GlobSet to combine negated
+// patterns with others.
+func (g *Glob) Match(name string, isDir bool) bool {
+	name = cleanGlobName(name)
+	for _, dir := range parentDirs(name) {
+		if g.matchName(dir, true) {
+			return true
+		}
+	}
+	return g.matchName(name, isDir)
+}
+
+// GlobSet is an ordered list of patterns, evaluated like the lines of a
+// .gitignore file: the last pattern matching a name determines whether it is
+// matched, negated patterns unmatch names matched by previous patterns, and
+// names within a matched directory are always matched.
+type GlobSet []*Glob
+
+// CompileGlobSet compiles a list of patterns into a GlobSet. Empty patterns
+// and patterns starting with `#' are ignored. Returns an error in case any
+// pattern is invalid.
+func CompileGlobSet(patterns []string) (GlobSet, error) {
+	var result GlobSet
+	for _, p := range patterns {
+		if trimTrailingSpaces(strings.TrimRight(p, "\r")) == "" || strings.HasPrefix(p, "#") {
+			continue
+		}
+		g, err := CompileGlob(p)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, g)
+	}
+	return result, nil
+}
+
+func (s GlobSet) matchName(name string, isDir bool) bool {
+	matched := false
+	for _, g := range s {
+		if g.matchName(name, isDir) {
+			matched = !g.negate
+		}
+	}
+	return matched
+}
+
+// Match indicates whether a given name is matched by the set
+func (s GlobSet) Match(name string, isDir bool) bool {
+	if len(s) == 0 {
+		return false
+	}
+	name = cleanGlobName(name)
+	for _, dir := range parentDirs(name) {
+		if s.matchName(dir, true) {
+			return true
+		}
+	}
+	return s.matchName(name, isDir)
+}
+
+// cleanGlobName normalises a slash-separated name to be matched against
+// patterns
+func cleanGlobName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// parentDirs returns all parent directories of a given name, starting from
+// the topmost one
+func parentDirs(name string) []string {
+	var result []string
+	for i := 0; i < len(name); i++ {
+		if name[i] == '/' {
+			result = append(result, name[:i])
+		}
+	}
+	return result
+}
@@ -0,0 +1,164 @@
+package fs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGlob(t *testing.T) {
+	corpus := []struct {
+		pattern string
+		name    string
+		isDir   bool
+		match   bool
+	}{
+		// Unanchored patterns
+		{"*.css", "file.css", false, true},
+		{"*.css", "foo/bar.css", false, true},
+		{"*.css", "foo/bar.cssx", false, false},
+		{"*.css", "foo.css/bar.txt", false, true},
+		{"foobar.xml", "foobar.xml", false, true},
+		{"foobar.xml", "a/b/foobar.xml", false, true},
+		{"foobar.xml", "a/xfoobar.xml", false, false},
+		{"foo", "foo/bar/baz.txt", false, true},
+
+		// Anchored patterns
+		{"/foo", "foo", false, true},
+		{"/foo", "bar/foo", false, false},
+		{"docs/*.md", "docs/index.md", false, true},
+		{"docs/*.md", "src/docs/index.md", false, false},
+		{"docs/*.md", "docs/api/index.md", false, false},
+		{"/docs/*.md", "docs/index.md", false, true},
+		{"test/foo/bar.c", "test/foo/bar.c", false, true},
+		{"test/foo/bar.c", "something/test/foo/bar.c", false, false},
+
+		// Wildcards
+		{"?.txt", "a.txt", false, true},
+		{"?.txt", "ab.txt", false, false},
+		{"a?c", "a/c", false, false},
+		{"a*c", "a/c", false, false},
+		{"a*c", "abbbc", false, true},
+		{"a*c", "ac", false, true},
+		{"file.??", "file.go", false, true},
+		{"file.??", "file.txt", false, false},
+
+		// Character classes
+		{"[abc].txt", "b.txt", false, true},
+		{"[abc].txt", "d.txt", false, false},
+		{"[a-c].txt", "c.txt", false, true},
+		{"[!a-c].txt", "c.txt", false, false},
+		{"[!a-c].txt", "d.txt", false, true},
+		{"[^a-c].txt", "d.txt", false, true},
+		{"[]].txt", "].txt", false, true},
+		{"[a-].txt", "-.txt", false, true},
+		{"[\\]x].txt", "].txt", false, true},
+		{"*.[ch]", "src/main.c", false, true},
+		{"*.[ch]", "src/main.h", false, true},
+		{"*.[ch]", "src/main.o", false, false},
+
+		// Double asterisks
+		{"**/foo", "foo", false, true},
+		{"**/foo", "a/b/foo", false, true},
+		{"**/foo/bar", "foo/bar", false, true},
+		{"**/foo/bar", "a/foo/bar", false, true},
+		{"**/foo/bar", "a/foo/baz", false, false},
+		{"abc/**", "abc/def", false, true},
+		{"abc/**", "abc/def/ghi", false, true},
+		{"abc/**", "abc", true, false},
+		{"abc/**", "xyz/abc/def", false, false},
+		{"a/**/b", "a/b", false, true},
+		{"a/**/b", "a/x/b", false, true},
+		{"a/**/b", "a/x/y/b", false, true},
+		{"a/**/b", "a/x/y/c", false, false},
+		{"a/**b", "a/xb", false, true},
+		{"a/**b", "a/x/b", false, false},
+		{"**", "anything/at/all", false, true},
+
+		// Directories
+		{"build/", "build", true, true},
+		{"build/", "build", false, false},
+		{"build/", "src/build", true, true},
+		{"build/", "build/out.bin", false, true},
+		{"build/", "src/build/out.bin", false, true},
+
+		// Escapes and spaces
+		{"\\*.txt", "*.txt", false, true},
+		{"\\*.txt", "a.txt", false, false},
+		{"\\?", "?", false, true},
+		{"\\!important", "!important", false, true},
+		{"\\#notes", "#notes", false, true},
+		{"trailing   ", "trailing", false, true},
+		{"trailing\\ ", "trailing ", false, true},
+		{"trailing\\ ", "trailing", false, false},
+		{"a.b", "axb", false, false},
+
+		// Names
+		{"*.go", "/main.go", false, true},
+		{"/foo", "./foo", false, true},
+	}
+
+	for _, c := range corpus {
+		g, err := CompileGlob(c.pattern)
+		require.NoError(t, err, c.pattern)
+		assert.Equal(t, c.match, g.Match(c.name, c.isDir), "pattern %q, name %q", c.pattern, c.name)
+	}
+}
+
+func TestGlobErrors(t *testing.T) {
+	for pattern, msg := range map[string]string{
+		"":         "invalid pattern `': pattern is empty",
+		"!":        "invalid pattern `!': pattern is empty",
+		"/":        "invalid pattern `/': pattern is empty",
+		"[abc":     "invalid pattern `[abc': unterminated character class",
+		"[]":       "invalid pattern `[]': unterminated character class",
+		"foo\\":    "invalid pattern `foo\\': trailing backslash",
+		"[a/b].go": "invalid pattern `[a/b].go': character class cannot match `/'",
+		"[z-a]":    "invalid pattern `[z-a]': error parsing regexp: invalid character class range: `z-a`",
+	} {
+		_, err := CompileGlob(pattern)
+		assert.EqualError(t, err, msg, pattern)
+	}
+	assert.Panics(t, func() { MustCompileGlob("[") })
+}
+
+func TestGlobSet(t *testing.T) {
+	s, err := CompileGlobSet([]string{
+		"# Generated files",
+		"*.html",
+		"!index.html",
+		"",
+		"vendor/",
+		"!vendor/keep.go",
+		"docs/**/*.md",
+		"!docs/README.md",
+	})
+	require.NoError(t, err)
+	assert.Len(t, s, 6)
+	assert.True(t, s[1].Negated())
+	assert.Equal(t, "!index.html", s[1].String())
+
+	expectations := map[string]bool{
+		"page.html":          true,
+		"site/page.html":     true,
+		"index.html":         false,
+		"site/index.html":    false,
+		"vendor/lib.go":      true,
+		"vendor/keep.go":     true, // Files within excluded directories cannot be re-included
+		"docs/guide.md":      true,
+		"docs/api/ref.md":    true,
+		"docs/README.md":     false,
+		"src/main.go":        false,
+		"site/vendor/lib.go": true,
+	}
+	for name, expected := range expectations {
+		assert.Equal(t, expected, s.Match(name, false), name)
+	}
+
+	_, err = CompileGlobSet([]string{"*.go", "[oops"})
+	assert.EqualError(t, err, "invalid pattern `[oops': unterminated character class")
+
+	var empty GlobSet
+	assert.False(t, empty.Match("anything", false))
+}
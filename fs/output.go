@@ -0,0 +1,359 @@
+package fs
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Output represents a writable file system receiving a rendered template.
+// Names are slash-separated paths relative to the output's root, following
+// the same rules used by io/fs. Implementations must be safe for concurrent
+// use.
+type Output interface {
+	// MkdirAll creates a directory with a given name and permissions, along
+	// with any necessary parents. Does nothing in case the directory already
+	// exists.
+	MkdirAll(name string, perm os.FileMode) error
+
+	// Create creates or truncates a file with a given name, returning a
+	// writer for its contents. The file has the provided mode, and is only
+	// guaranteed to be complete once the returned writer is closed.
+	Create(name string, perm os.FileMode) (io.WriteCloser, error)
+
+	// Stat returns a os.FileInfo describing a file with a given name. Returns
+	// an error satisfying os.IsNotExist in case it does not exist.
+	Stat(name string) (os.FileInfo, error)
+}
+
+// WriteFile writes data to a file with a given name and mode within an
+// Output, creating or truncating it.
+func WriteFile(out Output, name string, data []byte, perm os.FileMode) error {
+	w, err := out.Create(name, perm)
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(data); err != nil {
+		_ = w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func validateName(op, name string) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return nil
+}
+
+// DirOutput is an Output writing to a directory in the operating system's
+// file system.
+type DirOutput struct {
+	root string
+}
+
+// NewDirOutput returns a new DirOutput writing into a given root directory.
+func NewDirOutput(root string) *DirOutput {
+	return &DirOutput{root: root}
+}
+
+func (d *DirOutput) path(op, name string) (string, error) {
+	if err := validateName(op, name); err != nil {
+		return "", err
+	}
+	return filepath.Join(d.root, filepath.FromSlash(name)), nil
+}
+
+// MkdirAll implements Output
+func (d *DirOutput) MkdirAll(name string, perm os.FileMode) error {
+	p, err := d.path("mkdir", name)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(p, perm)
+}
+
+// Create implements Output. The file mode is applied regardless of the
+// process' umask.
+func (d *DirOutput) Create(name string, perm os.FileMode) (io.WriteCloser, error) {
+	p, err := d.path("create", name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return nil, err
+	}
+	if err = f.Chmod(perm); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// Symlink implements SymlinkOutput. target is written as-is, so relative
+// targets are resolved from the link's directory.
+func (d *DirOutput) Symlink(target, name string) error {
+	p, err := d.path("symlink", name)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(p), os.ModePerm); err != nil {
+		return err
+	}
+	if stat, err := os.Lstat(p); err == nil && !stat.IsDir() {
+		if err = os.Remove(p); err != nil {
+			return err
+		}
+	}
+	return os.Symlink(filepath.FromSlash(target), p)
+}
+
+// Stat implements Output
+func (d *DirOutput) Stat(name string) (os.FileInfo, error) {
+	p, err := d.path("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Stat(p)
+}
+
+// memFileInfo implements os.FileInfo for files kept by a MemOutput
+type memFileInfo struct {
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+}
+
+func (m memFileInfo) Name() string       { return path.Base(m.name) }
+func (m memFileInfo) Size() int64        { return m.size }
+func (m memFileInfo) Mode() os.FileMode  { return m.mode }
+func (m memFileInfo) ModTime() time.Time { return m.modTime }
+func (m memFileInfo) IsDir() bool        { return m.mode.IsDir() }
+func (m memFileInfo) Sys() interface{}   { return nil }
+
+type memFile struct {
+	data    []byte
+	mode    os.FileMode
+	modTime time.Time
+}
+
+// MemOutput is an Output keeping all files and directories in memory, which
+// can be later inspected, modified, or copied into another Output.
+type MemOutput struct {
+	mu    sync.Mutex
+	files map[string]*memFile
+}
+
+// NewMemOutput returns a new, empty MemOutput
+func NewMemOutput() *MemOutput {
+	return &MemOutput{files: map[string]*memFile{}}
+}
+
+func (m *MemOutput) mkdirAll(name string, perm os.FileMode) error {
+	for dir := name; dir != "."; dir = path.Dir(dir) {
+		if f, ok := m.files[dir]; ok {
+			if !f.mode.IsDir() {
+				return &fs.PathError{Op: "mkdir", Path: dir, Err: fs.ErrExist}
+			}
+			continue
+		}
+		m.files[dir] = &memFile{mode: os.ModeDir | perm.Perm(), modTime: time.Now()}
+	}
+	return nil
+}
+
+// MkdirAll implements Output
+func (m *MemOutput) MkdirAll(name string, perm os.FileMode) error {
+	if err := validateName("mkdir", name); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.mkdirAll(name, perm)
+}
+
+type memWriter struct {
+	bytes.Buffer
+	out  *MemOutput
+	file *memFile
+}
+
+func (w *memWriter) Close() error {
+	w.out.mu.Lock()
+	defer w.out.mu.Unlock()
+	w.file.data = w.Bytes()
+	w.file.modTime = time.Now()
+	return nil
+}
+
+// Create implements Output. Parent directories are created as needed.
+func (m *MemOutput) Create(name string, perm os.FileMode) (io.WriteCloser, error) {
+	if err := validateName("create", name); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if f, ok := m.files[name]; ok && f.mode.IsDir() {
+		return nil, &fs.PathError{Op: "create", Path: name, Err: fs.ErrExist}
+	}
+	if err := m.mkdirAll(path.Dir(name), os.ModePerm); err != nil {
+		return nil, err
+	}
+	f := &memFile{mode: perm, modTime: time.Now()}
+	m.files[name] = f
+	return &memWriter{out: m, file: f}, nil
+}
+
+// Symlink implements SymlinkOutput. Symbolic links are kept as files with
+// os.ModeSymlink set, whose contents are their targets.
+func (m *MemOutput) Symlink(target, name string) error {
+	if err := validateName("symlink", name); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if f, ok := m.files[name]; ok && f.mode.IsDir() {
+		return &fs.PathError{Op: "symlink", Path: name, Err: fs.ErrExist}
+	}
+	if err := m.mkdirAll(path.Dir(name), os.ModePerm); err != nil {
+		return err
+	}
+	m.files[name] = &memFile{data: []byte(target), mode: os.ModeSymlink | os.ModePerm, modTime: time.Now()}
+	return nil
+}
+
+// Stat implements Output
+func (m *MemOutput) Stat(name string) (os.FileInfo, error) {
+	if err := validateName("stat", name); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return memFileInfo{name: name, size: int64(len(f.data)), mode: f.mode, modTime: f.modTime}, nil
+}
+
+// Names returns the names of all files and directories in the MemOutput,
+// sorted so that directories come before their contents.
+func (m *MemOutput) Names() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	names := make([]string, 0, len(m.files))
+	for n := range m.files {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ReadFile returns the contents of a file with a given name
+func (m *MemOutput) ReadFile(name string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	} else if f.mode.IsDir() {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrInvalid}
+	}
+	return append([]byte(nil), f.data...), nil
+}
+
+// Remove removes a file or an empty directory with a given name
+func (m *MemOutput) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.files[name]
+	if !ok {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	if f.mode.IsDir() {
+		for n := range m.files {
+			if path.Dir(n) == name {
+				return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrExist}
+			}
+		}
+	}
+	delete(m.files, name)
+	return nil
+}
+
+// CopyTo copies all files and directories from the MemOutput into another
+// Output, preserving their modes. Copying symbolic links requires out to
+// implement SymlinkOutput.
+func (m *MemOutput) CopyTo(out Output) error {
+	for _, n := range m.Names() {
+		stat, err := m.Stat(n)
+		if err != nil {
+			return err
+		}
+		if stat.IsDir() {
+			if err = out.MkdirAll(n, stat.Mode().Perm()); err != nil {
+				return err
+			}
+			continue
+		}
+		data, err := m.ReadFile(n)
+		if err != nil {
+			return err
+		}
+		if stat.Mode()&os.ModeSymlink != 0 {
+			if err = Symlink(out, string(data), n); err != nil {
+				return err
+			}
+			continue
+		}
+		if err = WriteFile(out, n, data, stat.Mode()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CopyFS copies all files and directories contained in fsys into a given
+// directory within out, preserving their modes. Only regular files and
+// directories are supported.
+func CopyFS(out Output, dir string, fsys fs.FS) error {
+	return fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		dest := path.Join(dir, p)
+		if d.IsDir() {
+			return out.MkdirAll(dest, info.Mode().Perm())
+		} else if !info.Mode().IsRegular() {
+			return &fs.PathError{Op: "copy", Path: p, Err: fs.ErrInvalid}
+		}
+
+		src, err := fsys.Open(p)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		w, err := out.Create(dest, info.Mode())
+		if err != nil {
+			return err
+		}
+		if _, err = io.Copy(w, src); err != nil {
+			_ = w.Close()
+			return err
+		}
+		return w.Close()
+	})
+}
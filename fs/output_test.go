@@ -0,0 +1,27 @@
+package fs
+
+import (
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopyFS(t *testing.T) {
+	out := NewMemOutput()
+	fsys := fstest.MapFS{
+		"a/b.txt": {Data: []byte("b"), Mode: 0755},
+		"c.txt":   {Data: []byte("c"), Mode: 0644},
+	}
+	require.NoError(t, CopyFS(out, "dst", fsys))
+	assert.Equal(t, []string{"dst", "dst/a", "dst/a/b.txt", "dst/c.txt"}, out.Names())
+
+	stat, err := out.Stat("dst/a/b.txt")
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), stat.Mode().Perm())
+	contents, err := out.ReadFile("dst/c.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "c", string(contents))
+}
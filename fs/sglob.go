@@ -26,6 +26,11 @@ var repls = []repl{
 	{regexp.MustCompile(`\*`), `([^/]*)`},
 }
 
+// CreateSGlob converts a glob pattern into a regular expression, returning nil
+// in case the pattern is empty or invalid.
+//
+// Deprecated: CreateSGlob only approximates glob semantics. Use CompileGlob
+// or CompileGlobSet instead.
 func CreateSGlob(line string) *regexp.Regexp {
 	line = strings.TrimRight(line, "\r")
 	line = strings.TrimSpace(line)
@@ -0,0 +1,237 @@
+package fs
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// StagedOutput is an Output writing into a staging directory, only moving
+// its contents into a destination directory once Commit is called. This
+// allows partial results to be discarded through Rollback in case rendering
+// fails. Stat describes files written to the staging directory, as well as
+// the ones already present in the destination.
+type StagedOutput struct {
+	root    string
+	staging string
+	dir     *DirOutput
+}
+
+// NewStagedOutput returns a new StagedOutput for a given destination
+// directory, which may or may not exist. In case it exists, the staging
+// directory is placed within it; otherwise, it is placed next to it, and
+// parent directories are created as needed.
+func NewStagedOutput(root string) (*StagedOutput, error) {
+	stat, err := os.Stat(root)
+	if err == nil && !stat.IsDir() {
+		return nil, fmt.Errorf("destination %s is not a directory", root)
+	} else if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	parent, prefix := root, ".g8-staging"
+	if err != nil {
+		parent, prefix = filepath.Dir(root), "."+filepath.Base(root)+".g8"
+		if err := os.MkdirAll(parent, os.ModePerm); err != nil {
+			return nil, err
+		}
+	}
+
+	staging, err := makeStagingDir(parent, prefix)
+	if err != nil {
+		return nil, err
+	}
+	return &StagedOutput{root: root, staging: staging, dir: NewDirOutput(staging)}, nil
+}
+
+// makeStagingDir creates a new, uniquely named directory within parent
+func makeStagingDir(parent, prefix string) (string, error) {
+	// os.MkdirTemp always uses 0700; use os.Mkdir instead so the staging
+	// directory gets the same permissions a regular directory would.
+	for i := 0; ; i++ {
+		dir := filepath.Join(parent, fmt.Sprintf("%s-%d-%d", prefix, time.Now().UnixNano(), i))
+		err := os.Mkdir(dir, os.ModePerm)
+		if err == nil {
+			return dir, nil
+		}
+		if !os.IsExist(err) || i >= 100 {
+			return "", err
+		}
+	}
+}
+
+// MkdirAll implements Output
+func (s *StagedOutput) MkdirAll(name string, perm os.FileMode) error {
+	return s.dir.MkdirAll(name, perm)
+}
+
+// Create implements Output
+func (s *StagedOutput) Create(name string, perm os.FileMode) (io.WriteCloser, error) {
+	return s.dir.Create(name, perm)
+}
+
+// Symlink implements SymlinkOutput
+func (s *StagedOutput) Symlink(target, name string) error {
+	return s.dir.Symlink(target, name)
+}
+
+// Stat implements Output. Files written to the staging directory take
+// precedence over the ones present in the destination.
+func (s *StagedOutput) Stat(name string) (os.FileInfo, error) {
+	stat, err := s.dir.Stat(name)
+	if err == nil || !os.IsNotExist(err) {
+		return stat, err
+	}
+	return NewDirOutput(s.root).Stat(name)
+}
+
+// Commit moves all staged files into the destination. In case the destination
+// does not exist, the staging directory is atomically renamed to it;
+// otherwise, staged files are moved into it one by one, replacing existing
+// ones, which is not atomic. Replaced files are backed up first, and changes
+// are reverted on a best-effort basis in case moving any file fails. The
+// staging directory is removed in all cases.
+func (s *StagedOutput) Commit() error {
+	if _, err := os.Lstat(s.root); os.IsNotExist(err) {
+		if err = os.Rename(s.staging, s.root); err != nil {
+			_ = os.RemoveAll(s.staging)
+		}
+		return err
+	} else if err != nil {
+		_ = os.RemoveAll(s.staging)
+		return err
+	}
+
+	m := &stagedMerge{root: s.root, staging: s.staging}
+	err := filepath.WalkDir(s.staging, m.move)
+	if err != nil {
+		if undoErr := m.undo(); undoErr != nil {
+			// Keep backups around, as they may hold the only copy of
+			// replaced files.
+			_ = os.RemoveAll(s.staging)
+			return fmt.Errorf("%s; additionally, restoring %s failed: %s (replaced files are kept in %s)", err, s.root, undoErr, m.backup)
+		}
+	}
+	_ = os.RemoveAll(s.staging)
+	if m.backup != "" {
+		_ = os.RemoveAll(m.backup)
+	}
+	return err
+}
+
+// stagedChange records a single change applied to a destination by Commit,
+// so that it can be reverted.
+type stagedChange struct {
+	path   string
+	backup string
+	isDir  bool
+}
+
+// stagedMerge moves staged files into an existing destination, keeping track
+// of changes made to it.
+type stagedMerge struct {
+	root    string
+	staging string
+	backup  string
+	changes []stagedChange
+}
+
+// move is a fs.WalkDirFunc moving a staged entry into the destination
+func (m *stagedMerge) move(p string, d fs.DirEntry, err error) error {
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(m.staging, p)
+	if err != nil || rel == "." {
+		return err
+	}
+	dest := filepath.Join(m.root, rel)
+	stat, err := os.Lstat(dest)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	exists := err == nil
+
+	if d.IsDir() {
+		if exists && !stat.IsDir() {
+			return fmt.Errorf("cannot replace %s with a directory", dest)
+		} else if exists {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if err = os.Mkdir(dest, info.Mode().Perm()); err != nil {
+			return err
+		}
+		m.changes = append(m.changes, stagedChange{path: dest, isDir: true})
+		return nil
+	}
+
+	change := stagedChange{path: dest}
+	if exists {
+		if stat.IsDir() {
+			return fmt.Errorf("cannot replace directory %s with a file", dest)
+		}
+		if m.backup == "" {
+			if m.backup, err = makeStagingDir(filepath.Dir(m.staging), filepath.Base(m.staging)+".backup"); err != nil {
+				return err
+			}
+		}
+		change.backup = filepath.Join(m.backup, rel)
+		if err = os.MkdirAll(filepath.Dir(change.backup), os.ModePerm); err != nil {
+			return err
+		}
+		if err = os.Rename(dest, change.backup); err != nil {
+			return err
+		}
+	}
+	if err = os.Rename(p, dest); err != nil {
+		if change.backup != "" {
+			// Let undo restore the replaced file
+			m.changes = append(m.changes, change)
+		}
+		return err
+	}
+	m.changes = append(m.changes, change)
+	return nil
+}
+
+// undo reverts all recorded changes in reverse order, returning the first
+// error found.
+func (m *stagedMerge) undo() error {
+	var result error
+	for i := len(m.changes) - 1; i >= 0; i-- {
+		c := m.changes[i]
+		var err error
+		switch {
+		case c.isDir:
+			err = os.Remove(c.path)
+		case c.backup != "":
+			err = os.Rename(c.backup, c.path)
+		default:
+			err = os.Remove(c.path)
+		}
+		result = firstError(result, err)
+	}
+	return result
+}
+
+func firstError(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Rollback discards all staged files, leaving the destination untouched. It
+// may safely be called after Commit.
+func (s *StagedOutput) Rollback() error {
+	return os.RemoveAll(s.staging)
+}
@@ -0,0 +1,100 @@
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func dirNames(t *testing.T, dir string) []string {
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	var result []string
+	for _, e := range entries {
+		result = append(result, e.Name())
+	}
+	return result
+}
+
+func readString(t *testing.T, name string) string {
+	data, err := os.ReadFile(name)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestStagedOutputNewDestination(t *testing.T) {
+	parent := t.TempDir()
+	root := filepath.Join(parent, "out")
+	out, err := NewStagedOutput(root)
+	require.NoError(t, err)
+	require.NoError(t, out.MkdirAll("a", 0755))
+	require.NoError(t, WriteFile(out, "a/b.txt", []byte("b"), 0644))
+
+	_, err = os.Stat(root)
+	assert.True(t, os.IsNotExist(err))
+	require.NoError(t, out.Commit())
+	assert.Equal(t, "b", readString(t, filepath.Join(root, "a", "b.txt")))
+	assert.Equal(t, []string{"out"}, dirNames(t, parent))
+	assert.NoError(t, out.Rollback())
+}
+
+func TestStagedOutputExistingDestination(t *testing.T) {
+	parent := t.TempDir()
+	root := filepath.Join(parent, "out")
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "a"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "a", "b.txt"), []byte("old"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "keep.txt"), []byte("keep"), 0644))
+
+	out, err := NewStagedOutput(root)
+	require.NoError(t, err)
+	// The parent directory is never written to
+	assert.Equal(t, []string{"out"}, dirNames(t, parent))
+
+	require.NoError(t, out.MkdirAll("a", 0755))
+	require.NoError(t, out.MkdirAll("c", 0755))
+	require.NoError(t, WriteFile(out, "a/b.txt", []byte("new"), 0644))
+	require.NoError(t, WriteFile(out, "c/d.txt", []byte("d"), 0644))
+	stat, err := out.Stat("keep.txt")
+	require.NoError(t, err)
+	assert.False(t, stat.IsDir())
+
+	require.NoError(t, out.Commit())
+	assert.Equal(t, "new", readString(t, filepath.Join(root, "a", "b.txt")))
+	assert.Equal(t, "d", readString(t, filepath.Join(root, "c", "d.txt")))
+	assert.Equal(t, "keep", readString(t, filepath.Join(root, "keep.txt")))
+	assert.Equal(t, []string{"a", "c", "keep.txt"}, dirNames(t, root))
+}
+
+func TestStagedOutputCommitFailure(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "a.txt"), []byte("old"), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "z"), 0755))
+
+	out, err := NewStagedOutput(root)
+	require.NoError(t, err)
+	require.NoError(t, WriteFile(out, "a.txt", []byte("new"), 0644))
+	require.NoError(t, out.MkdirAll("b", 0755))
+	require.NoError(t, WriteFile(out, "b/c.txt", []byte("c"), 0644))
+	// Files cannot replace directories, so committing fails after a.txt
+	// and b/ were already moved.
+	require.NoError(t, WriteFile(out, "z", []byte("z"), 0644))
+
+	err = out.Commit()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "cannot replace directory")
+	assert.Equal(t, "old", readString(t, filepath.Join(root, "a.txt")))
+	assert.Equal(t, []string{"a.txt", "z"}, dirNames(t, root))
+	assert.NoError(t, out.Rollback())
+}
+
+func TestStagedOutputRollback(t *testing.T) {
+	parent := t.TempDir()
+	out, err := NewStagedOutput(filepath.Join(parent, "out"))
+	require.NoError(t, err)
+	require.NoError(t, WriteFile(out, "a.txt", []byte("a"), 0644))
+	require.NoError(t, out.Rollback())
+	assert.Empty(t, dirNames(t, parent))
+}
@@ -0,0 +1,139 @@
+package fs
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// SymlinkPolicy determines how symbolic links within a template are handled
+type SymlinkPolicy int
+
+const (
+	// SymlinkFollow handles symbolic links as the files or directories they
+	// point to, copying their contents. Links pointing outside of the
+	// template are rejected. This is the default policy.
+	SymlinkFollow SymlinkPolicy = iota
+	// SymlinkPreserve keeps symbolic links as such. Their targets are
+	// rendered as templates, allowing them to refer to properties.
+	SymlinkPreserve
+	// SymlinkSkip ignores symbolic links
+	SymlinkSkip
+)
+
+var symlinkPolicyNames = map[SymlinkPolicy]string{
+	SymlinkFollow:   "follow",
+	SymlinkPreserve: "preserve",
+	SymlinkSkip:     "skip",
+}
+
+func (s SymlinkPolicy) String() string {
+	if n, ok := symlinkPolicyNames[s]; ok {
+		return n
+	}
+	return fmt.Sprintf("SymlinkPolicy(%d)", int(s))
+}
+
+// ParseSymlinkPolicy returns the SymlinkPolicy with a given name: either
+// `follow', `preserve' or `skip'.
+func ParseSymlinkPolicy(name string) (SymlinkPolicy, error) {
+	for p, n := range symlinkPolicyNames {
+		if n == name {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown symlink policy `%s'", name)
+}
+
+// ReadLinkFS is implemented by file systems able to describe symbolic links.
+// File systems returned by NewDirFS implement it.
+type ReadLinkFS interface {
+	fs.FS
+
+	// ReadLink returns the target of the symbolic link with a given name
+	ReadLink(name string) (string, error)
+
+	// Lstat returns a fs.FileInfo describing a file with a given name,
+	// without following symbolic links.
+	Lstat(name string) (fs.FileInfo, error)
+}
+
+type dirFS struct {
+	fs.FS
+	root string
+}
+
+// NewDirFS returns a file system for the tree of files rooted at a given
+// directory, like os.DirFS, which also implements ReadLinkFS.
+func NewDirFS(dir string) fs.FS {
+	return dirFS{FS: os.DirFS(dir), root: dir}
+}
+
+func (d dirFS) path(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return filepath.Join(d.root, filepath.FromSlash(name)), nil
+}
+
+// ReadLink implements ReadLinkFS. Targets are returned as slash-separated
+// paths.
+func (d dirFS) ReadLink(name string) (string, error) {
+	p, err := d.path("readlink", name)
+	if err != nil {
+		return "", err
+	}
+	target, err := os.Readlink(p)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(target), nil
+}
+
+// Lstat implements ReadLinkFS
+func (d dirFS) Lstat(name string) (fs.FileInfo, error) {
+	p, err := d.path("lstat", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Lstat(p)
+}
+
+// ReadLink returns the target of the symbolic link with a given name within
+// fsys, which must implement ReadLinkFS.
+func ReadLink(fsys fs.FS, name string) (string, error) {
+	if l, ok := fsys.(ReadLinkFS); ok {
+		return l.ReadLink(name)
+	}
+	return "", &fs.PathError{Op: "readlink", Path: name, Err: fmt.Errorf("file system does not support symbolic links")}
+}
+
+// Lstat returns a fs.FileInfo describing a file with a given name within
+// fsys without following symbolic links. In case fsys does not implement
+// ReadLinkFS, symbolic links are followed.
+func Lstat(fsys fs.FS, name string) (fs.FileInfo, error) {
+	if l, ok := fsys.(ReadLinkFS); ok {
+		return l.Lstat(name)
+	}
+	return fs.Stat(fsys, name)
+}
+
+// SymlinkOutput is implemented by outputs able to hold symbolic links
+type SymlinkOutput interface {
+	Output
+
+	// Symlink creates a symbolic link with a given name pointing to target,
+	// replacing any existing file with the same name. Parent directories
+	// are created as needed.
+	Symlink(target, name string) error
+}
+
+// Symlink creates a symbolic link with a given name pointing to target
+// within out, which must implement SymlinkOutput.
+func Symlink(out Output, target, name string) error {
+	if s, ok := out.(SymlinkOutput); ok {
+		return s.Symlink(target, name)
+	}
+	return &fs.PathError{Op: "symlink", Path: name, Err: fmt.Errorf("output does not support symbolic links")}
+}
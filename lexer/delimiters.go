@@ -0,0 +1,84 @@
+package lexer
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Delimiters determines the strings surrounding templates, conditionals and
+// comments. Open and Close may be the same string.
+type Delimiters struct {
+	Open  string
+	Close string
+}
+
+// DefaultDelimiters contains the delimiters used by giter8 templates
+var DefaultDelimiters = Delimiters{Open: "$", Close: "$"}
+
+// Validate returns an error in case any delimiter is empty, or contains
+// whitespace or backslashes.
+func (d Delimiters) Validate() error {
+	for _, v := range []string{d.Open, d.Close} {
+		if v == "" {
+			return fmt.Errorf("delimiters cannot be empty")
+		}
+		for _, r := range v {
+			if unicode.IsSpace(r) || r == ESCAPE {
+				return fmt.Errorf("invalid delimiter `%s': delimiters cannot contain whitespace or backslashes", v)
+			}
+		}
+	}
+	return nil
+}
+
+func (d Delimiters) String() string {
+	return d.Open + " " + d.Close
+}
+
+// ParseDelimiters parses delimiters written as `OPEN CLOSE', separated by
+// whitespace, such as `{{ }}' or `@@ @@'.
+func ParseDelimiters(s string) (Delimiters, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		return Delimiters{}, fmt.Errorf("invalid delimiters `%s': expected an opening and a closing delimiter separated by a space", s)
+	}
+	d := Delimiters{Open: fields[0], Close: fields[1]}
+	return d, d.Validate()
+}
+
+var delimitersDirectiveRegexp = regexp.MustCompile(`g8:delimiters\s+(\S+)\s+(\S+)`)
+
+// ExtractDelimiters looks for a directive setting the delimiters used by a
+// given file, such as `# g8:delimiters {{ }}'. The directive must be on the
+// file's first line, or on its second line in case the first one is a
+// shebang. When found, returns the delimiters, the file contents without the
+// directive's line, and true.
+func ExtractDelimiters(data string) (Delimiters, string, bool, error) {
+	start := 0
+	if strings.HasPrefix(data, "#!") {
+		idx := strings.IndexByte(data, '\n')
+		if idx == -1 {
+			return Delimiters{}, data, false, nil
+		}
+		start = idx + 1
+	}
+
+	end := strings.IndexByte(data[start:], '\n')
+	if end == -1 {
+		end = len(data)
+	} else {
+		end += start + 1
+	}
+	m := delimitersDirectiveRegexp.FindStringSubmatch(data[start:end])
+	if m == nil {
+		return Delimiters{}, data, false, nil
+	}
+
+	d := Delimiters{Open: m[1], Close: m[2]}
+	if err := d.Validate(); err != nil {
+		return Delimiters{}, data, false, err
+	}
+	return d, data[:start] + data[end:], true, nil
+}
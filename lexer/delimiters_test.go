@@ -0,0 +1,63 @@
+package lexer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenizeDelimiters(t *testing.T) {
+	braces := Delimiters{Open: "{{", Close: "}}"}
+	ast, err := TokenizeDelimiters("echo ${HOME} {{name;format=\"upper\"}} \\{{x}} { }\n{{! note !}}\n{{if(a.truthy)}}$1{{endif}}", braces)
+	require.NoError(t, err)
+	require.Equal(t, 5, len(ast))
+	assert.Equal(t, "echo ${HOME} ", ast[0].(*Literal).String)
+	assert.Equal(t, "name", ast[1].(*Template).Name)
+	assert.Equal(t, map[string]string{"format": "upper"}, ast[1].(*Template).Options)
+	assert.Equal(t, " {{x}} { }\n", ast[2].(*Literal).String)
+	assert.Equal(t, "note", ast[3].(*Comment).Text)
+	cond := ast[4].(*Conditional)
+	assert.Equal(t, "$1", cond.Then[0].(*Literal).String)
+
+	ast, err = TokenizeDelimiters("@@name@@ costs $5 @", Delimiters{Open: "@@", Close: "@@"})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(ast))
+	assert.Equal(t, " costs $5 @", ast[1].(*Literal).String)
+
+	_, err = TokenizeDelimiters("{{name}", braces)
+	assert.Error(t, err)
+	_, err = TokenizeDelimiters("", Delimiters{Open: "{ {", Close: "}}"})
+	assert.Error(t, err)
+}
+
+func TestParseDelimiters(t *testing.T) {
+	d, err := ParseDelimiters(" {{  }} ")
+	require.NoError(t, err)
+	assert.Equal(t, Delimiters{Open: "{{", Close: "}}"}, d)
+
+	for _, s := range []string{"", "{{", "{{ }} x", `\{ }`} {
+		_, err = ParseDelimiters(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestExtractDelimiters(t *testing.T) {
+	for _, c := range []struct {
+		in, rest string
+		found    bool
+		d        Delimiters
+	}{
+		{"# g8:delimiters @@ @@\necho @@name@@\n", "echo @@name@@\n", true, Delimiters{"@@", "@@"}},
+		{"#!/bin/sh\n# g8:delimiters {{ }}\necho\n", "#!/bin/sh\necho\n", true, Delimiters{"{{", "}}"}},
+		{"<!-- g8:delimiters [[ ]] -->", "", true, Delimiters{"[[", "]]"}},
+		{"echo\n# g8:delimiters @@ @@\n", "echo\n# g8:delimiters @@ @@\n", false, Delimiters{}},
+		{"#!/bin/sh", "#!/bin/sh", false, Delimiters{}},
+	} {
+		d, rest, found, err := ExtractDelimiters(c.in)
+		require.NoError(t, err)
+		assert.Equal(t, c.found, found, c.in)
+		assert.Equal(t, c.rest, rest, c.in)
+		assert.Equal(t, c.d, d, c.in)
+	}
+}
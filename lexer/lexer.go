@@ -16,6 +16,7 @@ const (
 	KindLiteral
 	KindTemplate
 	KindConditional
+	KindComment
 )
 
 const DEBUG = false
@@ -75,6 +76,21 @@ func (c Conditional) Parent() Node {
 	return c.parentNode
 }
 
+// Comment represents a comment left for template maintainers, written as
+// `$! text !$'. Comments produce no output when rendered.
+type Comment struct {
+	Text       string
+	nodeParent Node
+}
+
+func (c Comment) Kind() Kind {
+	return KindComment
+}
+
+func (c Comment) Parent() Node {
+	return c.nodeParent
+}
+
 type AST []Node
 
 // IsPureLiteral determines whether the AST only contains literals, meaning
@@ -88,6 +104,41 @@ func (a AST) IsPureLiteral() bool {
 	return true
 }
 
+// References returns the names of all properties referenced by the AST,
+// including the ones used by conditionals, in the order they first appear.
+func (a AST) References() []string {
+	var result []string
+	seen := map[string]bool{}
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			result = append(result, name)
+		}
+	}
+	var walk func(tree AST)
+	var walkConditional func(c *Conditional)
+	walkConditional = func(c *Conditional) {
+		add(c.Property)
+		walk(c.Then)
+		for _, e := range c.ElseIf {
+			walkConditional(e)
+		}
+		walk(c.Else)
+	}
+	walk = func(tree AST) {
+		for _, n := range tree {
+			switch v := n.(type) {
+			case *Template:
+				add(v.Name)
+			case *Conditional:
+				walkConditional(v)
+			}
+		}
+	}
+	walk(a)
+	return result
+}
+
 type state int
 
 const (
@@ -103,6 +154,7 @@ const (
 	stateTemplateOptionValueBegin
 	stateTemplateOptionValue
 	stateTemplateOptionOrEnd
+	stateComment
 )
 
 func (s state) String() string {
@@ -131,6 +183,8 @@ func (s state) String() string {
 		return "stateTemplateOptionValue"
 	case stateTemplateOptionOrEnd:
 		return "stateTemplateOptionOrEnd"
+	case stateComment:
+		return "stateComment"
 	default:
 		return "WTF!"
 	}
@@ -140,6 +194,7 @@ const (
 	ESCAPE     = rune('\\')
 	DELIM      = rune('$')
 	NEWLINE    = rune('\n')
+	CR         = rune('\r')
 	SEMICOLON  = rune(';')
 	EQUALS     = rune('=')
 	QUOT       = rune('"')
@@ -151,6 +206,7 @@ const (
 	DOT        = rune('.')
 	UNDERSCORE = rune('_')
 	DASH       = rune('-')
+	BANG       = rune('!')
 	TRUTHY     = "truthy"
 	PRESENT    = "present"
 )
@@ -189,11 +245,49 @@ type Tokenizer struct {
 	lastFedRune rune
 	idx         int
 	line        int
+
+	delims  Delimiters
+	open    []rune
+	close   []rune
+	pending []rune
+
+	// Standalone lines, containing only whitespace and control tags, are
+	// removed from the output along with their line break. lineContent
+	// indicates whether the current line contains anything else, and
+	// lineControl whether it contains control tags. lineStart holds the
+	// position within tmp where the current line starts, and lineLiterals the
+	// literals committed from the current line, to be trimmed in case it is
+	// standalone.
+	lineContent  bool
+	lineControl  bool
+	lineStart    int
+	lineLiterals []lineLiteral
+}
+
+// lineLiteral refers to a committed literal whose contents starting at a
+// given byte offset belong to the current line
+type lineLiteral struct {
+	literal *Literal
+	from    int
 }
 
-// NewTokenizer prepares a new Tokenizer
+// NewTokenizer prepares a new Tokenizer using DefaultDelimiters
 func NewTokenizer() *Tokenizer {
+	t, _ := NewTokenizerDelimiters(DefaultDelimiters)
+	return t
+}
+
+// NewTokenizerDelimiters prepares a new Tokenizer recognising templates
+// between a given set of delimiters. Returns an error in case delimiters are
+// invalid, as described by Delimiters.Validate.
+func NewTokenizerDelimiters(d Delimiters) (*Tokenizer, error) {
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
 	return &Tokenizer{
+		delims:          d,
+		open:            []rune(d.Open),
+		close:           []rune(d.Close),
 		ast:             nil,
 		tmp:             sb.New(),
 		templateName:    sb.New(),
@@ -204,7 +298,7 @@ func NewTokenizer() *Tokenizer {
 		lastFedRune:     0,
 		idx:             0,
 		line:            0,
-	}
+	}, nil
 }
 
 func (t *Tokenizer) pushStack() {
@@ -264,8 +358,34 @@ func (t *Tokenizer) commitLiteral() {
 	if t.tmp.Len() == 0 {
 		return
 	}
-	t.pushAST(&Literal{String: t.tmp.String(), nodeParent: t.currentConditional})
+	lit := &Literal{String: t.tmp.String(), nodeParent: t.currentConditional}
+	if !t.lineContent && t.lineStart < t.tmp.Len() {
+		// Everything after lineStart is whitespace, and therefore takes a
+		// single byte per rune.
+		from := len(lit.String) - (t.tmp.Len() - t.lineStart)
+		t.lineLiterals = append(t.lineLiterals, lineLiteral{literal: lit, from: from})
+	}
+	t.pushAST(lit)
 	t.tmp.Reset()
+	t.lineStart = 0
+}
+
+// endLine is called whenever a line break is found in a literal, or once
+// the input ends. In case the current line is standalone, its whitespace is
+// removed, and endLine returns true to indicate the line break must be
+// dropped as well.
+func (t *Tokenizer) endLine() bool {
+	standalone := t.lineControl && !t.lineContent
+	if standalone {
+		t.tmp.Delete(t.tmp.Len() - t.lineStart)
+		for _, l := range t.lineLiterals {
+			l.literal.String = l.literal.String[0:l.from]
+		}
+	}
+	t.lineContent = false
+	t.lineControl = false
+	t.lineLiterals = nil
+	return standalone
 }
 
 func (t *Tokenizer) commitTemplate() {
@@ -277,6 +397,7 @@ func (t *Tokenizer) commitTemplate() {
 		Options:    t.templateOptions,
 		nodeParent: t.currentConditional,
 	})
+	t.lineContent = true
 	t.templateName.Reset()
 	t.templateOptions = nil
 }
@@ -330,6 +451,7 @@ func (t *Tokenizer) prepareConditional() error {
 	}
 	t.currentConditional = cond
 	t.templateName.Reset()
+	t.lineControl = true
 	return nil
 }
 
@@ -368,6 +490,50 @@ func (t *Tokenizer) Feed(chr rune) error {
 		if chr == NEWLINE {
 			t.line++
 		}
+	}()
+	t.pending = append(t.pending, chr)
+	return t.drain(false)
+}
+
+// drain feeds pending runes to the state machine, translating delimiters
+// into a single delim event. Delimiters opening templates are only
+// recognised while in a literal, and closing ones everywhere else. Runes that
+// may be the beginning of a delimiter are kept pending until more input is
+// available, or until eof is true.
+func (t *Tokenizer) drain(eof bool) error {
+	for len(t.pending) > 0 {
+		delim := t.close
+		if t._state == stateLiteral {
+			delim = t.open
+		}
+		n := 0
+		for n < len(delim) && n < len(t.pending) && t.pending[n] == delim[n] {
+			n++
+		}
+
+		switch {
+		case n == len(delim):
+			t.pending = t.pending[n:]
+			if err := t.feed(delim[n-1], true); err != nil {
+				return err
+			}
+		case n == len(t.pending) && !eof:
+			return nil
+		default:
+			chr := t.pending[0]
+			t.pending = t.pending[1:]
+			if err := t.feed(chr, false); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// feed advances the state machine by a single rune. delim indicates whether
+// the rune completes a delimiter.
+func (t *Tokenizer) feed(chr rune, delim bool) error {
+	defer func() {
 		t.lastFedRune = chr
 	}()
 	if DEBUG {
@@ -379,17 +545,29 @@ func (t *Tokenizer) Feed(chr rune) error {
 	}
 	switch t._state {
 	case stateLiteral:
-		if chr == DELIM && t.lastRune() != ESCAPE {
+		if delim && t.lastRune() != ESCAPE {
 			t.commitLiteral()
 			t.transition(stateTemplateName)
 			return nil
-		} else if chr == DELIM && t.lastRune() == ESCAPE {
+		} else if delim && t.lastRune() == ESCAPE {
 			t.tmp.DeleteLast()
+			t.tmp.WriteString(t.delims.Open)
+			t.lineContent = true
+			return nil
+		} else if chr == NEWLINE {
+			if !t.endLine() {
+				t.tmp.WriteRune(chr)
+			}
+			t.lineStart = t.tmp.Len()
+			return nil
+		}
+		if !isSpace(chr) && chr != CR {
+			t.lineContent = true
 		}
 		t.tmp.WriteRune(chr)
 
 	case stateTemplateName:
-		if chr == DELIM {
+		if delim {
 			if t.templateName.Len() == 0 {
 				return t.unexpectedToken(DELIM)
 			}
@@ -413,6 +591,7 @@ func (t *Tokenizer) Feed(chr rune) error {
 				t.replaceStack(stateTemplateConditionalElse)
 				t.transition(stateLiteral)
 				t.templateName.Reset()
+				t.lineControl = true
 				return nil
 			} else if currentName == "endif" {
 				if ok, _ := t.currentStack(); !ok {
@@ -428,6 +607,7 @@ func (t *Tokenizer) Feed(chr rune) error {
 				t.currentConditional = prevCond.(*Conditional)
 				t.transition(stateLiteral)
 				t.templateName.Reset()
+				t.lineControl = true
 				return nil
 			}
 			t.commitTemplate()
@@ -458,6 +638,10 @@ func (t *Tokenizer) Feed(chr rune) error {
 			t.templateName.Reset()
 			return nil
 		}
+		if t.templateName.Len() == 0 && chr == BANG {
+			t.transition(stateComment)
+			return nil
+		}
 		if t.templateName.Len() == 0 && !unicode.IsLetter(chr) {
 			return t.unexpectedToken(chr)
 		}
@@ -472,7 +656,7 @@ func (t *Tokenizer) Feed(chr rune) error {
 		}
 		t.templateName.WriteRune(chr)
 	case stateTemplateCombinedFormatter:
-		if chr == DELIM {
+		if delim {
 			if t.tmp.Len() == 0 {
 				return t.unexpectedToken(chr)
 			}
@@ -486,6 +670,26 @@ func (t *Tokenizer) Feed(chr rune) error {
 		}
 		t.tmp.WriteRune(chr)
 
+	case stateComment:
+		// tmp holds the comment's text, as the preceding literal was already
+		// committed. Its last rune must be the closing BANG.
+		if delim && t.lastRune() == BANG && t.tmp.Len() > 0 {
+			t.tmp.DeleteLast()
+			t.pushAST(&Comment{
+				Text:       strings.TrimSpace(t.tmp.String()),
+				nodeParent: t.currentConditional,
+			})
+			t.tmp.Reset()
+			t.lineStart = 0
+			t.lineControl = true
+			t.transition(stateLiteral)
+			return nil
+		} else if delim {
+			t.tmp.WriteString(t.delims.Close)
+			return nil
+		}
+		t.tmp.WriteRune(chr)
+
 	case stateTemplateConditionalExpression:
 		if chr == RPAREN {
 			if t.templateName.Len() == 0 {
@@ -500,7 +704,7 @@ func (t *Tokenizer) Feed(chr rune) error {
 		t.templateName.WriteRune(chr)
 
 	case stateTemplateConditionalExpressionEnd:
-		if chr != DELIM {
+		if !delim {
 			return t.unexpectedToken(chr)
 		}
 		if err := t.prepareConditional(); err != nil {
@@ -509,7 +713,7 @@ func (t *Tokenizer) Feed(chr rune) error {
 		t.transition(stateLiteral)
 
 	case stateTemplateOptionName:
-		if chr == DELIM {
+		if delim {
 			if t.templateName.Len() == 0 {
 				return t.unexpectedToken(DELIM)
 			}
@@ -532,7 +736,10 @@ func (t *Tokenizer) Feed(chr rune) error {
 		return t.unexpectedToken(chr)
 
 	case stateTemplateOptionValue:
-		if chr == QUOT && t.lastRune() != ESCAPE {
+		if delim {
+			t.optionValue.WriteString(t.delims.Close)
+			return nil
+		} else if chr == QUOT && t.lastRune() != ESCAPE {
 			t.transition(stateTemplateOptionOrEnd)
 			t.commitTemplateOption()
 			return nil
@@ -547,7 +754,7 @@ func (t *Tokenizer) Feed(chr rune) error {
 		} else if chr == COMMA {
 			t.transition(stateTemplateOptionName)
 			return nil
-		} else if chr == DELIM {
+		} else if delim {
 			t.transition(stateLiteral)
 			t.commitTemplate()
 			return nil
@@ -561,6 +768,9 @@ func (t *Tokenizer) Feed(chr rune) error {
 // Finish completes the parsing process and returns the generated AST, or an
 // error
 func (t *Tokenizer) Finish() (AST, error) {
+	if err := t.drain(true); err != nil {
+		return nil, err
+	}
 	if t._state != stateLiteral {
 		return nil, UnexpectedEOFErr{
 			idx:   t.idx,
@@ -569,6 +779,7 @@ func (t *Tokenizer) Finish() (AST, error) {
 		}
 	}
 
+	t.endLine()
 	t.commitLiteral()
 
 	return cleanAST(t.ast), nil
@@ -577,7 +788,20 @@ func (t *Tokenizer) Finish() (AST, error) {
 // Tokenize takes all runes from the provided string, feeds an internal
 // Tokenizer instance, and returns the result by calling Finish
 func Tokenize(data string) (ast AST, err error) {
-	t := NewTokenizer()
+	return tokenize(NewTokenizer(), data)
+}
+
+// TokenizeDelimiters works like Tokenize, using a given set of delimiters
+// instead of DefaultDelimiters.
+func TokenizeDelimiters(data string, d Delimiters) (AST, error) {
+	t, err := NewTokenizerDelimiters(d)
+	if err != nil {
+		return nil, err
+	}
+	return tokenize(t, data)
+}
+
+func tokenize(t *Tokenizer, data string) (ast AST, err error) {
 	for _, d := range []rune(data) {
 		if err = t.Feed(d); err != nil {
 			return
@@ -586,18 +810,15 @@ func Tokenize(data string) (ast AST, err error) {
 	return t.Finish()
 }
 
+// cleanAST removes empty literals left behind by standalone lines
 func cleanAST(ast AST) AST {
 	if ast == nil {
 		return nil
 	}
 	var newAST AST
-	for i, node := range ast {
-		if i > 0 && i+1 < len(ast) && node.Kind() == KindLiteral {
-			if node.(*Literal).String == "\n" &&
-				ast[i-1].Kind() == KindConditional &&
-				ast[i+1].Kind() == KindConditional {
-				continue
-			}
+	for _, node := range ast {
+		if node.Kind() == KindLiteral && node.(*Literal).String == "" {
+			continue
 		}
 
 		if node.Kind() == KindConditional {
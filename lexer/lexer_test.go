@@ -113,3 +113,51 @@ func TestAST_IsPureLiteral(t *testing.T) {
 	require.NoError(t, err)
 	assert.True(t, ast.IsPureLiteral())
 }
+
+func TestAST_References(t *testing.T) {
+	template := `$name$ $if(docker.truthy)$$image;format="lower"$$elseif(k8s.present)$$name$$else$$fallback__upper$$endif$`
+	ast, err := Tokenize(template)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"name", "docker", "image", "k8s", "fallback"}, ast.References())
+}
+
+func TestStandaloneLines(t *testing.T) {
+	ast, err := Tokenize("héllo\n  $if(a.truthy)$  \nthen\n\t$else$\nelse\n$endif$\nbye $name$\n")
+	require.NoError(t, err)
+	require.Equal(t, 5, len(ast))
+	assert.Equal(t, "héllo\n", ast[0].(*Literal).String)
+	cond := ast[1].(*Conditional)
+	assert.Equal(t, AST{&Literal{String: "then\n", nodeParent: cond}}, cond.Then)
+	assert.Equal(t, AST{&Literal{String: "else\n", nodeParent: cond}}, cond.Else)
+	assert.Equal(t, "bye ", ast[2].(*Literal).String)
+	assert.Equal(t, "\n", ast[4].(*Literal).String)
+
+	// Lines containing anything other than control tags are kept as-is
+	ast, err = Tokenize("  $name$ $if(a.truthy)$\n$endif$\n")
+	require.NoError(t, err)
+	require.Equal(t, 4, len(ast))
+	assert.Equal(t, "\n", ast[3].(*Conditional).Then[0].(*Literal).String)
+}
+
+func TestComments(t *testing.T) {
+	ast, err := Tokenize("a$! inline ! comment !$b")
+	require.NoError(t, err)
+	require.Equal(t, 3, len(ast))
+	assert.Equal(t, KindComment, ast[1].Kind())
+	assert.Equal(t, "inline ! comment", ast[1].(*Comment).Text)
+	assert.Equal(t, "b", ast[2].(*Literal).String)
+
+	// Standalone comments are removed along with their lines
+	ast, err = Tokenize("a\n  $! multi-line\n  comment !$\n$if(x.truthy)$\n$! nested !$\nb\n$endif$\n")
+	require.NoError(t, err)
+	require.Equal(t, 3, len(ast))
+	assert.Equal(t, "a\n", ast[0].(*Literal).String)
+	assert.Equal(t, "multi-line\n  comment", ast[1].(*Comment).Text)
+	cond := ast[2].(*Conditional)
+	require.Equal(t, 2, len(cond.Then))
+	assert.Equal(t, "nested", cond.Then[0].(*Comment).Text)
+	assert.Equal(t, "b\n", cond.Then[1].(*Literal).String)
+
+	_, err = Tokenize("a $! unterminated comment $")
+	assert.Error(t, err)
+}
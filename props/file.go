@@ -0,0 +1,121 @@
+package props
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ParseJSON takes a JSON document containing a single object, and returns a
+// Pairs slice representing its members, in the same order they appear in the
+// document. Members must be strings, numbers, booleans or null; null values
+// are represented as empty strings.
+func ParseJSON(data []byte) (Pairs, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '{' {
+		return nil, fmt.Errorf("expected a JSON object")
+	}
+
+	var result Pairs
+	for dec.More() {
+		tok, err = dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key := tok.(string)
+		tok, err = dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		var value string
+		switch v := tok.(type) {
+		case string:
+			value = v
+		case json.Number:
+			value = v.String()
+		case bool:
+			value = strconv.FormatBool(v)
+		case nil:
+			value = ""
+		default:
+			return nil, fmt.Errorf("value of `%s' must be a string, number, boolean or null", key)
+		}
+		result.Merge(Pairs{{K: key, V: value}})
+	}
+	if _, err = dec.Token(); err != nil {
+		return nil, err
+	}
+	if _, err = dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected data after JSON object")
+	}
+	return result, nil
+}
+
+// ParseYAML takes a YAML document containing a single mapping, and returns a
+// Pairs slice representing its entries, in the same order they appear in the
+// document. Values must be scalars; null values are represented as empty
+// strings.
+func ParseYAML(data []byte) (Pairs, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("expected a YAML mapping at line %d", root.Line)
+	}
+
+	var result Pairs
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		if key.Kind != yaml.ScalarNode {
+			return nil, fmt.Errorf("unexpected non-scalar key at line %d", key.Line)
+		}
+		if value.Kind != yaml.ScalarNode {
+			return nil, fmt.Errorf("value of `%s' at line %d must be a scalar", key.Value, value.Line)
+		}
+		v := value.Value
+		if value.Tag == "!!null" {
+			v = ""
+		}
+		result.Merge(Pairs{{K: key.Value, V: v}})
+	}
+	return result, nil
+}
+
+// ReadFile reads and parses a given file, returning its contents as a Pairs
+// slice. The file format is determined by its extension, and must be either
+// `.properties', `.json', `.yaml' or `.yml'.
+func ReadFile(name string) (Pairs, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".properties":
+		return ParseProperties(string(data))
+	case ".json":
+		return ParseJSON(data)
+	case ".yaml", ".yml":
+		return ParseYAML(data)
+	}
+	return nil, fmt.Errorf("%s: unsupported file format", name)
+}
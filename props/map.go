@@ -0,0 +1,116 @@
+package props
+
+// Map is an ordered collection of properties. Unlike Pairs, looking up a
+// property by name takes constant time, while iteration through Pairs and
+// Keys still follows the order in which properties were first added.
+// Pointers returned by Find and FetchPair remain valid as the Map grows, and
+// changes made through them are visible to the Map.
+type Map struct {
+	pairs []*Pair
+	index map[string]int
+}
+
+// NewMap returns a new Map containing all values from a given Pairs slice.
+// In case the slice contains the same key more than once, its last value is
+// used.
+func NewMap(in Pairs) *Map {
+	m := &Map{
+		pairs: make([]*Pair, 0, len(in)),
+		index: make(map[string]int, len(in)),
+	}
+	m.Merge(in)
+	return m
+}
+
+// Len returns the amount of properties in the Map
+func (m *Map) Len() int {
+	return len(m.pairs)
+}
+
+// Find returns a Pair with with name, or nil
+func (m *Map) Find(name string) *Pair {
+	if idx, ok := m.index[name]; ok {
+		return m.pairs[idx]
+	}
+	return nil
+}
+
+// Fetch attempts to find a pair with a given name, and returns its value and
+// true. Otherwise, returns an empty string and false.
+func (m *Map) Fetch(name string) (string, bool) {
+	if pair := m.Find(name); pair != nil {
+		return pair.V, true
+	}
+	return "", false
+}
+
+// FetchPair attempts to find a pair with a given name, and returns the pair
+// representation and true. Returns nil and false otherwise.
+func (m *Map) FetchPair(name string) (*Pair, bool) {
+	if pair := m.Find(name); pair != nil {
+		return pair, true
+	}
+	return nil, false
+}
+
+// MustGet returns the value of a Pair with a given name, or panics.
+func (m *Map) MustGet(name string) string {
+	pair := m.Find(name)
+	if pair == nil {
+		panic("Pair with key " + name + " not found")
+	}
+	return pair.V
+}
+
+// Set defines the value of a property with a given name. Existing properties
+// keep their position; new ones are added to the end of the Map.
+func (m *Map) Set(name, value string) {
+	if pair := m.Find(name); pair != nil {
+		pair.V = value
+		return
+	}
+	m.index[name] = len(m.pairs)
+	m.pairs = append(m.pairs, &Pair{K: name, V: value})
+}
+
+// Delete removes a property with a given name from the Map, returning whether
+// it was present.
+func (m *Map) Delete(name string) bool {
+	idx, ok := m.index[name]
+	if !ok {
+		return false
+	}
+	delete(m.index, name)
+	m.pairs = append(m.pairs[:idx], m.pairs[idx+1:]...)
+	for i := idx; i < len(m.pairs); i++ {
+		m.index[m.pairs[i].K] = i
+	}
+	return true
+}
+
+// Merge adds a given Pairs value into the current Map, overwriting any
+// current value with values from the provided slice.
+func (m *Map) Merge(in Pairs) {
+	for _, pair := range in {
+		m.Set(pair.K, pair.V)
+	}
+}
+
+// Keys returns the names of all properties in the Map, in order.
+func (m *Map) Keys() []string {
+	result := make([]string, len(m.pairs))
+	for i, p := range m.pairs {
+		result[i] = p.K
+	}
+	return result
+}
+
+// Pairs returns a copy of all properties in the Map as a Pairs slice, in
+// order.
+func (m *Map) Pairs() Pairs {
+	result := make(Pairs, len(m.pairs))
+	for i, p := range m.pairs {
+		result[i] = *p
+	}
+	return result
+}
@@ -0,0 +1,312 @@
+package props
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+type logicalLine struct {
+	runes []rune
+	line  int
+}
+
+func isPropertyWhitespace(chr rune) bool {
+	return chr == ' ' || chr == '\t' || chr == '\f'
+}
+
+func isLineTerminator(chr rune) bool {
+	return chr == '\n' || chr == '\r'
+}
+
+// readLogicalLines splits a given text into logical lines, following the same
+// rules used by java.util.Properties: blank lines and comments (lines starting
+// with `#' or `!') are discarded, leading whitespace is ignored, and natural
+// lines ending with an odd amount of backslashes are joined with the next
+// natural line, ignoring its leading whitespace.
+func readLogicalLines(text string) []logicalLine {
+	var (
+		result             []logicalLine
+		buf                []rune
+		line               = 1
+		startLine          = 1
+		skipWhitespace     = true
+		isNewLine          = true
+		isCommentLine      = false
+		appendedLineBegin  = false
+		precedingBackslash = false
+		skipLF             = false
+	)
+
+	for _, chr := range text {
+		if skipLF {
+			skipLF = false
+			if chr == '\n' {
+				continue
+			}
+		}
+		if chr == '\n' || chr == '\r' {
+			line++
+		}
+		if skipWhitespace {
+			if isPropertyWhitespace(chr) {
+				continue
+			}
+			if !appendedLineBegin && isLineTerminator(chr) {
+				if chr == '\r' {
+					skipLF = true
+				}
+				continue
+			}
+			skipWhitespace = false
+			appendedLineBegin = false
+		}
+		if isNewLine {
+			isNewLine = false
+			startLine = line
+			if chr == '#' || chr == '!' {
+				isCommentLine = true
+				continue
+			}
+		}
+
+		if !isLineTerminator(chr) {
+			buf = append(buf, chr)
+			if chr == '\\' {
+				precedingBackslash = !precedingBackslash
+			} else {
+				precedingBackslash = false
+			}
+			continue
+		}
+
+		if chr == '\r' {
+			skipLF = true
+		}
+		if isCommentLine || len(buf) == 0 {
+			isCommentLine = false
+			isNewLine = true
+			skipWhitespace = true
+			buf = buf[:0]
+			continue
+		}
+		if precedingBackslash {
+			// Line continues on the next natural line
+			buf = buf[:len(buf)-1]
+			skipWhitespace = true
+			appendedLineBegin = true
+			precedingBackslash = false
+			continue
+		}
+		result = append(result, logicalLine{runes: buf, line: startLine})
+		buf = nil
+		isNewLine = true
+		skipWhitespace = true
+	}
+
+	if !isCommentLine && len(buf) > 0 {
+		if precedingBackslash {
+			buf = buf[:len(buf)-1]
+		}
+		result = append(result, logicalLine{runes: buf, line: startLine})
+	}
+	return result
+}
+
+// splitKeyValue splits a logical line into its raw (still escaped) key and
+// value. The key ends at the first unescaped `=', `:' or whitespace
+// character; the separator and any whitespace surrounding it are discarded.
+func splitKeyValue(runes []rune) (key, value []rune) {
+	keyLen := 0
+	valueStart := len(runes)
+	hasSeparator := false
+	precedingBackslash := false
+
+	for keyLen < len(runes) {
+		chr := runes[keyLen]
+		if (chr == '=' || chr == ':') && !precedingBackslash {
+			valueStart = keyLen + 1
+			hasSeparator = true
+			break
+		} else if isPropertyWhitespace(chr) && !precedingBackslash {
+			valueStart = keyLen + 1
+			break
+		}
+		if chr == '\\' {
+			precedingBackslash = !precedingBackslash
+		} else {
+			precedingBackslash = false
+		}
+		keyLen++
+	}
+
+	for valueStart < len(runes) {
+		chr := runes[valueStart]
+		if !isPropertyWhitespace(chr) {
+			if !hasSeparator && (chr == '=' || chr == ':') {
+				hasSeparator = true
+			} else {
+				break
+			}
+		}
+		valueStart++
+	}
+
+	return runes[0:keyLen], runes[valueStart:]
+}
+
+func hexValue(chr rune) (rune, bool) {
+	switch {
+	case chr >= '0' && chr <= '9':
+		return chr - '0', true
+	case chr >= 'a' && chr <= 'f':
+		return chr - 'a' + 10, true
+	case chr >= 'A' && chr <= 'F':
+		return chr - 'A' + 10, true
+	}
+	return 0, false
+}
+
+// unescape converts escape sequences (\t, \n, \r, \f, \uXXXX and escaped
+// characters) into the characters they represent. \uXXXX sequences encoding
+// UTF-16 surrogate pairs are combined into a single character.
+func unescape(runes []rune, line int) (string, error) {
+	result := make([]rune, 0, len(runes))
+	for i := 0; i < len(runes); i++ {
+		chr := runes[i]
+		if chr != '\\' {
+			result = append(result, chr)
+			continue
+		}
+		i++
+		if i >= len(runes) {
+			// A lone trailing backslash is discarded
+			break
+		}
+		chr = runes[i]
+		switch chr {
+		case 't':
+			chr = '\t'
+		case 'r':
+			chr = '\r'
+		case 'n':
+			chr = '\n'
+		case 'f':
+			chr = '\f'
+		case 'u':
+			if i+4 >= len(runes) {
+				return "", fmt.Errorf("malformed \\uxxxx encoding at line %d", line)
+			}
+			var value rune
+			for _, h := range runes[i+1 : i+5] {
+				v, ok := hexValue(h)
+				if !ok {
+					return "", fmt.Errorf("malformed \\uxxxx encoding at line %d", line)
+				}
+				value = value<<4 | v
+			}
+			i += 4
+			chr = value
+		}
+		result = append(result, chr)
+	}
+
+	return combineSurrogates(result), nil
+}
+
+func combineSurrogates(runes []rune) string {
+	result := make([]rune, 0, len(runes))
+	for i := 0; i < len(runes); i++ {
+		chr := runes[i]
+		if !utf16.IsSurrogate(chr) {
+			result = append(result, chr)
+			continue
+		}
+		if i+1 < len(runes) {
+			if r := utf16.DecodeRune(chr, runes[i+1]); r != utf8.RuneError {
+				result = append(result, r)
+				i++
+				continue
+			}
+		}
+		result = append(result, utf8.RuneError)
+	}
+	return string(result)
+}
+
+// ParseProperties takes a list of properties commonly contained within a
+// `default.properties` file, and returns a Pairs slice representing them.
+// Parsing follows the format used by java.util.Properties: keys and values
+// may be separated by `=', `:' or whitespace, comments start with `#' or `!',
+// lines may be continued with a trailing backslash, and both keys and values
+// support escape sequences, including \uXXXX. When a key is declared more
+// than once, its last value is used.
+func ParseProperties(text string) (Pairs, error) {
+	var result Pairs
+	for _, l := range readLogicalLines(text) {
+		rawKey, rawValue := splitKeyValue(l.runes)
+		key, err := unescape(rawKey, l.line)
+		if err != nil {
+			return nil, err
+		}
+		value, err := unescape(rawValue, l.line)
+		if err != nil {
+			return nil, err
+		}
+		result.Merge(Pairs{{K: key, V: value}})
+	}
+	return result, nil
+}
+
+// escapeProperty escapes a key or value so it can be safely written into a
+// properties file. Whitespace is escaped everywhere for keys, and only when
+// leading for values. Characters outside the printable ASCII range are
+// written as \uXXXX sequences.
+func escapeProperty(s string, isKey bool) string {
+	var b strings.Builder
+	for i, chr := range s {
+		switch chr {
+		case ' ':
+			if i == 0 || isKey {
+				b.WriteString(`\ `)
+			} else {
+				b.WriteRune(chr)
+			}
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '=', ':', '#', '!', '\\':
+			b.WriteRune('\\')
+			b.WriteRune(chr)
+		default:
+			if chr >= 0x20 && chr <= 0x7e {
+				b.WriteRune(chr)
+			} else if r1, r2 := utf16.EncodeRune(chr); r1 != utf8.RuneError {
+				fmt.Fprintf(&b, `\u%04X\u%04X`, r1, r2)
+			} else {
+				fmt.Fprintf(&b, `\u%04X`, chr)
+			}
+		}
+	}
+	return b.String()
+}
+
+// WriteProperties writes all pairs to a given io.Writer using the properties
+// format understood by ParseProperties, escaping keys and values as needed.
+func (p Pairs) WriteProperties(w io.Writer) error {
+	buf := bufio.NewWriter(w)
+	for _, pair := range p {
+		if _, err := fmt.Fprintf(buf, "%s=%s\n", escapeProperty(pair.K, true), escapeProperty(pair.V, false)); err != nil {
+			return err
+		}
+	}
+	return buf.Flush()
+}
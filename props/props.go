@@ -1,17 +1,7 @@
 package props
 
 import (
-	"fmt"
 	"strings"
-	"unicode"
-)
-
-type state int
-
-const (
-	statePropKey state = iota
-	statePropValue
-	stateComment
 )
 
 type Pair struct{ K, V string }
@@ -29,11 +19,24 @@ func (p Pair) Truthy() bool {
 
 type Pairs []Pair
 
-// Find returns a Pair with with name, or nil
-func (p *Pairs) Find(name string) *Pair {
-	for _, p := range *p {
-		if p.K == name {
-			return &p
+// Lookup is implemented by property collections capable of locating
+// properties by name, such as Pairs and Map.
+type Lookup interface {
+	// Fetch returns the value of a property with a given name and true, or an
+	// empty string and false in case it does not exist.
+	Fetch(name string) (string, bool)
+
+	// FetchPair returns the Pair representing a property with a given name
+	// and true, or nil and false in case it does not exist.
+	FetchPair(name string) (*Pair, bool)
+}
+
+// Find returns a Pair with with name, or nil. The returned Pair points to the
+// slice's storage, so changes made through it are visible in the slice.
+func (p Pairs) Find(name string) *Pair {
+	for i := range p {
+		if p[i].K == name {
+			return &p[i]
 		}
 	}
 	return nil
@@ -41,7 +44,7 @@ func (p *Pairs) Find(name string) *Pair {
 
 // Fetch attempts to find a pair with a given name, and returns its value and
 // true. Otherwise, returns an empty string and false.
-func (p *Pairs) Fetch(name string) (string, bool) {
+func (p Pairs) Fetch(name string) (string, bool) {
 	if pair := p.Find(name); pair != nil {
 		return pair.V, true
 	} else {
@@ -51,7 +54,7 @@ func (p *Pairs) Fetch(name string) (string, bool) {
 
 // FetchPair attempts to find a pair with a given name, and returns the pair
 // representation and true. Returns nil and false otherwise.
-func (p *Pairs) FetchPair(name string) (*Pair, bool) {
+func (p Pairs) FetchPair(name string) (*Pair, bool) {
 	if pair := p.Find(name); pair != nil {
 		return pair, true
 	}
@@ -89,7 +92,7 @@ func (p Pairs) indexOf(key string) int {
 }
 
 // MustGet returns the value of a Pair with a given name, or panics.
-func (p *Pairs) MustGet(name string) string {
+func (p Pairs) MustGet(name string) string {
 	pair := p.Find(name)
 	if pair == nil {
 		panic("Pair with key " + name + " not found")
@@ -97,55 +100,6 @@ func (p *Pairs) MustGet(name string) string {
 	return pair.V
 }
 
-// ParseProperties takes a list of properties commonly contained within a
-// `default.properties` file, and returns a Pairs slice representing them.
-func ParseProperties(text string) (Pairs, error) {
-	var result []Pair
-	s := statePropKey
-	var tmpKey strings.Builder
-	var tmpValue strings.Builder
-	for idx, chr := range []rune(text) {
-		switch s {
-		case statePropKey:
-			if tmpKey.Len() == 0 && chr == ' ' || chr == '\t' || chr == '\r' || chr == '\n' {
-				continue
-			}
-			if tmpKey.Len() == 0 && chr == '#' {
-				s = stateComment
-				continue
-			}
-			if tmpKey.Len() == 0 && !unicode.IsLetter(chr) {
-				return nil, fmt.Errorf("unexpected char %c at position %d", chr, idx)
-			}
-			if chr == '=' {
-				s = statePropValue
-				continue
-			}
-			tmpKey.WriteRune(chr)
-		case stateComment:
-			if chr == '\n' {
-				s = statePropKey
-			}
-		case statePropValue:
-			if chr == '\n' {
-				result = append(result, Pair{K: strings.TrimSpace(tmpKey.String()), V: strings.TrimSpace(tmpValue.String())})
-				tmpKey.Reset()
-				tmpValue.Reset()
-				s = statePropKey
-				continue
-			}
-			tmpValue.WriteRune(chr)
-		}
-	}
-
-	if s == statePropKey && tmpKey.Len() > 0 {
-		return nil, fmt.Errorf("unexpected end of input")
-	} else if s == statePropValue {
-		result = append(result, Pair{K: strings.TrimSpace(tmpKey.String()), V: strings.TrimSpace(tmpValue.String())})
-	}
-	return result, nil
-}
-
 // FromMap take a map and returns a Pairs based on its contents
 func FromMap(in map[string]string) Pairs {
 	var pairs Pairs
@@ -1,6 +1,8 @@
 package props
 
 import (
+	"bytes"
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -31,3 +33,164 @@ dashed-variable=value
 	assert.Equal(t, "production", allProps.MustGet("productionCluster"))
 	assert.Equal(t, "value", allProps.MustGet("dashed-variable"))
 }
+
+func TestPropsParsingJavaSyntax(t *testing.T) {
+	// Cases derived from the java.util.Properties#load documentation and
+	// behaviour.
+	tests := []struct {
+		name     string
+		input    string
+		expected Pairs
+	}{
+		{"equals separator", "Truth = Beauty", Pairs{{K: "Truth", V: "Beauty"}}},
+		{"colon separator", "  Truth:Beauty", Pairs{{K: "Truth", V: "Beauty"}}},
+		{"whitespace and colon", "Truth                    :Beauty", Pairs{{K: "Truth", V: "Beauty"}}},
+		{"whitespace separator", "Truth Beauty", Pairs{{K: "Truth", V: "Beauty"}}},
+		{"key without value", "cheeses", Pairs{{K: "cheeses", V: ""}}},
+		{"key with separator only", "cheeses=", Pairs{{K: "cheeses", V: ""}}},
+		{"line continuation", "fruits                           apple, banana, pear, \\\n" +
+			"                                  cantaloupe, watermelon, \\\n" +
+			"                                  kiwi, mango",
+			Pairs{{K: "fruits", V: "apple, banana, pear, cantaloupe, watermelon, kiwi, mango"}}},
+		{"continuation with crlf", "a=b\\\r\n   c\r\nd=e", Pairs{{K: "a", V: "bc"}, {K: "d", V: "e"}}},
+		{"escaped backslash does not continue", "a=b\\\\\nc=d", Pairs{{K: "a", V: "b\\"}, {K: "c", V: "d"}}},
+		{"continuation at end of input", "a=b\\", Pairs{{K: "a", V: "b"}}},
+		{"escaped separators in key", "\\:\\=key\\ with\\ spaces = value", Pairs{{K: ":=key with spaces", V: "value"}}},
+		{"separator in value", "a==b:c", Pairs{{K: "a", V: "=b:c"}}},
+		{"hash comment", "# comment\na=b", Pairs{{K: "a", V: "b"}}},
+		{"bang comment", "  ! comment\na=b", Pairs{{K: "a", V: "b"}}},
+		{"comment is not continued", "# comment \\\na=b", Pairs{{K: "a", V: "b"}}},
+		{"hash inside value", "a=b # c", Pairs{{K: "a", V: "b # c"}}},
+		{"continued line starting with hash", "a=b\\\n#c", Pairs{{K: "a", V: "b#c"}}},
+		{"blank lines", "\n\n  \t\na=b\n\r\n", Pairs{{K: "a", V: "b"}}},
+		{"cr line terminator", "a=b\rc=d", Pairs{{K: "a", V: "b"}, {K: "c", V: "d"}}},
+		{"trailing whitespace is kept", "a=b  ", Pairs{{K: "a", V: "b  "}}},
+		{"escape sequences", "a=\\t\\n\\r\\f\\q", Pairs{{K: "a", V: "\t\n\r\fq"}}},
+		{"unicode escapes", "\\u0041=\\u00e9t\\u00C9", Pairs{{K: "A", V: "étÉ"}}},
+		{"surrogate pairs", "a=\\uD83D\\uDE00", Pairs{{K: "a", V: "😀"}}},
+		{"non-ascii input", "ação=café", Pairs{{K: "ação", V: "café"}}},
+		{"keys starting with digits", "1st=first", Pairs{{K: "1st", V: "first"}}},
+		{"duplicated keys", "a=1\nb=2\na=3", Pairs{{K: "a", V: "3"}, {K: "b", V: "2"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ParseProperties(tt.input)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestPropsParsingMalformedUnicode(t *testing.T) {
+	for _, input := range []string{"a=\\u00", "a=\\uZZZZ", "\\u12=b"} {
+		_, err := ParseProperties(input)
+		assert.Error(t, err, input)
+	}
+}
+
+func TestWriteProperties(t *testing.T) {
+	pairs := Pairs{
+		{K: "name", V: "Project Name"},
+		{K: "key with spaces", V: "  leading spaces"},
+		{K: "sep:=", V: "a=b:c#d!e\\f"},
+		{K: "template", V: `$name;format="upper,snake"$`},
+		{K: "multiline", V: "line1\nline2\ttab"},
+		{K: "unicode", V: "café 😀"},
+		{K: "empty", V: ""},
+	}
+	var buf bytes.Buffer
+	require.NoError(t, pairs.WriteProperties(&buf))
+	assert.Equal(t, `name=Project Name
+key\ with\ spaces=\  leading spaces
+sep\:\==a\=b\:c\#d\!e\\f
+template=$name;format\="upper,snake"$
+multiline=line1\nline2\ttab
+unicode=caf\u00E9 \uD83D\uDE00
+empty=
+`, buf.String())
+
+	parsed, err := ParseProperties(buf.String())
+	require.NoError(t, err)
+	assert.Equal(t, pairs, parsed)
+}
+
+func TestParseJSON(t *testing.T) {
+	result, err := ParseJSON([]byte(`{"name": "Project", "coverage": 10.5, "docker": true, "empty": null}`))
+	require.NoError(t, err)
+	assert.Equal(t, Pairs{
+		{K: "name", V: "Project"},
+		{K: "coverage", V: "10.5"},
+		{K: "docker", V: "true"},
+		{K: "empty", V: ""},
+	}, result)
+
+	_, err = ParseJSON([]byte(`["name"]`))
+	assert.Error(t, err)
+	_, err = ParseJSON([]byte(`{"name": {"nested": true}}`))
+	assert.Error(t, err)
+	_, err = ParseJSON([]byte(`{"a": "1"} junk`))
+	assert.Error(t, err)
+	_, err = ParseJSON([]byte(`{"a": "1"}{"b": "2"}`))
+	assert.EqualError(t, err, "unexpected data after JSON object")
+	_, err = ParseJSON([]byte(`{"a": "1"`))
+	assert.Error(t, err)
+	_, err = ParseJSON([]byte("{\"a\": \"1\"}\n"))
+	assert.NoError(t, err)
+}
+
+func TestParseYAML(t *testing.T) {
+	result, err := ParseYAML([]byte(`
+name: Project
+coverage: 10
+docker: yes
+empty:
+`))
+	require.NoError(t, err)
+	assert.Equal(t, Pairs{
+		{K: "name", V: "Project"},
+		{K: "coverage", V: "10"},
+		{K: "docker", V: "yes"},
+		{K: "empty", V: ""},
+	}, result)
+
+	_, err = ParseYAML([]byte(`- name`))
+	assert.Error(t, err)
+	_, err = ParseYAML([]byte(`name: [a, b]`))
+	assert.Error(t, err)
+}
+
+func TestPairsFindPointsToStorage(t *testing.T) {
+	pairs := Pairs{{K: "a", V: "1"}, {K: "b", V: "2"}}
+	pairs.Find("b").V = "changed"
+	assert.Equal(t, "changed", pairs.MustGet("b"))
+}
+
+func TestMap(t *testing.T) {
+	m := NewMap(Pairs{{K: "a", V: "1"}, {K: "b", V: "2"}, {K: "a", V: "3"}})
+	assert.Equal(t, 2, m.Len())
+	assert.Equal(t, "3", m.MustGet("a"))
+
+	pair := m.Find("b")
+	for i := 0; i < 100; i++ {
+		m.Set(fmt.Sprintf("key%d", i), "value")
+	}
+	pair.V = "changed"
+	v, ok := m.Fetch("b")
+	assert.True(t, ok)
+	assert.Equal(t, "changed", v)
+
+	m.Merge(Pairs{{K: "c", V: "4"}, {K: "a", V: "5"}})
+	assert.True(t, m.Delete("key0"))
+	assert.False(t, m.Delete("key0"))
+	for i := 1; i < 100; i++ {
+		assert.True(t, m.Delete(fmt.Sprintf("key%d", i)))
+	}
+	assert.Equal(t, []string{"a", "b", "c"}, m.Keys())
+	assert.Equal(t, Pairs{{K: "a", V: "5"}, {K: "b", V: "changed"}, {K: "c", V: "4"}}, m.Pairs())
+
+	_, ok = m.FetchPair("missing")
+	assert.False(t, ok)
+
+	var _ Lookup = m
+	var _ Lookup = Pairs{}
+}
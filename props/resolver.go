@@ -0,0 +1,264 @@
+package props
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gympass/go-giter8/lexer"
+)
+
+// RenderFunc renders a given AST using values as properties, returning the
+// resulting string.
+type RenderFunc func(ast lexer.AST, values Pairs) (string, error)
+
+// CycleErr indicates that default values of a set of properties depend on
+// each other, and therefore cannot be computed.
+type CycleErr struct {
+	Keys []string
+}
+
+func (c CycleErr) Error() string {
+	return fmt.Sprintf("circular reference between properties: %s", strings.Join(c.Keys, " -> "))
+}
+
+// Resolver computes values of properties whose defaults may reference other
+// properties, regardless of the order they are declared in. Defaults are
+// always computed from the current set of answers, so changing an answer
+// through Set is reflected by all defaults depending on it.
+type Resolver struct {
+	keys     []string
+	order    []string
+	defaults map[string]lexer.AST
+	deps     map[string][]string
+	answers  Pairs
+	render   RenderFunc
+}
+
+// NewResolver creates a new Resolver for a given set of default values, using
+// render to compute them. Returns an error in case a default value cannot be
+// parsed, or in case defaults reference each other in a cycle.
+func NewResolver(defaults Pairs, render RenderFunc) (*Resolver, error) {
+	r := &Resolver{
+		defaults: map[string]lexer.AST{},
+		deps:     map[string][]string{},
+		render:   render,
+	}
+	for _, p := range defaults {
+		ast, err := lexer.Tokenize(p.V)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing property %s: %s", p.K, err)
+		}
+		if _, ok := r.defaults[p.K]; !ok {
+			r.keys = append(r.keys, p.K)
+		}
+		r.defaults[p.K] = ast
+	}
+	for _, k := range r.keys {
+		var deps []string
+		for _, ref := range r.defaults[k].References() {
+			if _, ok := r.defaults[ref]; ok {
+				deps = append(deps, ref)
+			}
+		}
+		r.deps[k] = deps
+	}
+
+	order, err := r.sort()
+	if err != nil {
+		return nil, err
+	}
+	r.order = order
+	return r, nil
+}
+
+// sort orders keys so every property comes after the ones its default
+// depends on. Among properties whose dependencies are satisfied, the one
+// declared first is picked, keeping the declaration order whenever possible.
+func (r *Resolver) sort() ([]string, error) {
+	done := map[string]bool{}
+	order := make([]string, 0, len(r.keys))
+	for len(order) < len(r.keys) {
+		picked := false
+		for _, k := range r.keys {
+			if done[k] {
+				continue
+			}
+			ready := true
+			for _, d := range r.deps[k] {
+				if !done[d] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				done[k] = true
+				order = append(order, k)
+				picked = true
+				break
+			}
+		}
+		if !picked {
+			return nil, r.findCycle(done)
+		}
+	}
+	return order, nil
+}
+
+// findCycle returns a CycleErr describing a cycle among keys not present in
+// done.
+func (r *Resolver) findCycle(done map[string]bool) error {
+	var start string
+	for _, k := range r.keys {
+		if !done[k] {
+			start = k
+			break
+		}
+	}
+
+	// Every pending key has at least one pending dependency; following them
+	// eventually revisits a key.
+	visited := map[string]int{}
+	var path []string
+	for k := start; ; {
+		if idx, ok := visited[k]; ok {
+			return CycleErr{Keys: append(path[idx:], k)}
+		}
+		visited[k] = len(path)
+		path = append(path, k)
+		for _, d := range r.deps[k] {
+			if !done[d] {
+				k = d
+				break
+			}
+		}
+	}
+}
+
+// Order returns all keys declared by the defaults, ordered so that every
+// property comes after the ones its default value depends on.
+func (r *Resolver) Order() []string {
+	return append([]string(nil), r.order...)
+}
+
+// Dependencies returns the keys of declared properties referenced by the
+// default value of a given property.
+func (r *Resolver) Dependencies(key string) []string {
+	return append([]string(nil), r.deps[key]...)
+}
+
+// Dependents returns the keys of all properties whose default values depend,
+// directly or not, on a given property. Those are the defaults affected when
+// the answer for key changes.
+func (r *Resolver) Dependents(key string) []string {
+	affected := map[string]bool{key: true}
+	var result []string
+	for _, k := range r.order {
+		for _, d := range r.deps[k] {
+			if affected[d] {
+				affected[k] = true
+				result = append(result, k)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// Set records an answer for a given property, which will be used instead of
+// its default value.
+func (r *Resolver) Set(key, value string) {
+	r.answers.Merge(Pairs{{K: key, V: value}})
+}
+
+// Unset removes an answer previously recorded through Set, causing the
+// property to use its default value again.
+func (r *Resolver) Unset(key string) {
+	if idx := r.answers.indexOf(key); idx != -1 {
+		r.answers = append(r.answers[:idx], r.answers[idx+1:]...)
+	}
+}
+
+// Answered returns whether an answer was recorded for a given property.
+func (r *Resolver) Answered(key string) bool {
+	return r.answers.indexOf(key) != -1
+}
+
+// Default computes the default value of a given property using the current
+// answers, and the defaults of unanswered properties it depends on.
+func (r *Resolver) Default(key string) (string, error) {
+	ast, ok := r.defaults[key]
+	if !ok {
+		return "", fmt.Errorf("property `%s' is not declared", key)
+	}
+	values := append(Pairs(nil), r.answers...)
+	if err := r.collect(key, &values, map[string]bool{}); err != nil {
+		return "", err
+	}
+	return r.renderDefault(key, ast, values)
+}
+
+// collect adds to values the defaults of all unanswered properties key
+// depends on.
+func (r *Resolver) collect(key string, values *Pairs, seen map[string]bool) error {
+	for _, d := range r.deps[key] {
+		if seen[d] || r.Answered(d) {
+			continue
+		}
+		seen[d] = true
+		if err := r.collect(d, values, seen); err != nil {
+			return err
+		}
+		v, err := r.renderDefault(d, r.defaults[d], *values)
+		if err != nil {
+			return err
+		}
+		values.Merge(Pairs{{K: d, V: v}})
+	}
+	return nil
+}
+
+func (r *Resolver) renderDefault(key string, ast lexer.AST, values Pairs) (string, error) {
+	v, err := r.render(ast, values)
+	if err != nil {
+		return "", fmt.Errorf("error populating property %s: %s", key, err)
+	}
+	return v, nil
+}
+
+// Value returns the answer recorded for a given property, or its default
+// value in case it has not been answered.
+func (r *Resolver) Value(key string) (string, error) {
+	if v, ok := r.answers.Fetch(key); ok {
+		return v, nil
+	}
+	return r.Default(key)
+}
+
+// Resolve computes the final value of all properties, returning them in the
+// same order they were declared, followed by answers for undeclared
+// properties.
+func (r *Resolver) Resolve() (Pairs, error) {
+	values := append(Pairs(nil), r.answers...)
+	for _, k := range r.order {
+		if r.Answered(k) {
+			continue
+		}
+		v, err := r.renderDefault(k, r.defaults[k], values)
+		if err != nil {
+			return nil, err
+		}
+		values.Merge(Pairs{{K: k, V: v}})
+	}
+
+	result := make(Pairs, 0, len(values))
+	for _, k := range r.keys {
+		v, _ := values.Fetch(k)
+		result = append(result, Pair{K: k, V: v})
+	}
+	for _, p := range r.answers {
+		if _, ok := r.defaults[p.K]; !ok {
+			result = append(result, p)
+		}
+	}
+	return result, nil
+}
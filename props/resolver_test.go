@@ -0,0 +1,108 @@
+package props
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gympass/go-giter8/lexer"
+)
+
+func substitute(ast lexer.AST, values Pairs) (string, error) {
+	var result strings.Builder
+	for _, n := range ast {
+		switch v := n.(type) {
+		case *lexer.Literal:
+			result.WriteString(v.String)
+		case *lexer.Template:
+			val, ok := values.Fetch(v.Name)
+			if !ok {
+				return "", fmt.Errorf("property `%s' is not defined", v.Name)
+			}
+			result.WriteString(val)
+		}
+	}
+	return result.String(), nil
+}
+
+func TestResolverForwardReferences(t *testing.T) {
+	defaults, err := ParseProperties(`package=$organization$.$name$
+name=project
+organization=com.foo
+`)
+	require.NoError(t, err)
+	r, err := NewResolver(defaults, substitute)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"name", "organization", "package"}, r.Order())
+	assert.Equal(t, []string{"organization", "name"}, r.Dependencies("package"))
+
+	def, err := r.Default("package")
+	require.NoError(t, err)
+	assert.Equal(t, "com.foo.project", def)
+
+	resolved, err := r.Resolve()
+	require.NoError(t, err)
+	assert.Equal(t, Pairs{
+		{K: "package", V: "com.foo.project"},
+		{K: "name", V: "project"},
+		{K: "organization", V: "com.foo"},
+	}, resolved)
+}
+
+func TestResolverRecomputesDependents(t *testing.T) {
+	defaults, err := ParseProperties(`name=project
+normalized=$name$-svc
+image=registry/$normalized$
+other=static
+`)
+	require.NoError(t, err)
+	r, err := NewResolver(defaults, substitute)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"normalized", "image"}, r.Dependents("name"))
+
+	r.Set("name", "first")
+	v, err := r.Value("image")
+	require.NoError(t, err)
+	assert.Equal(t, "registry/first-svc", v)
+
+	r.Set("name", "second")
+	v, err = r.Value("image")
+	require.NoError(t, err)
+	assert.Equal(t, "registry/second-svc", v)
+
+	r.Set("normalized", "custom")
+	r.Set("extra", "value")
+	resolved, err := r.Resolve()
+	require.NoError(t, err)
+	assert.Equal(t, Pairs{
+		{K: "name", V: "second"},
+		{K: "normalized", V: "custom"},
+		{K: "image", V: "registry/custom"},
+		{K: "other", V: "static"},
+		{K: "extra", V: "value"},
+	}, resolved)
+
+	r.Unset("normalized")
+	assert.False(t, r.Answered("normalized"))
+	v, err = r.Value("image")
+	require.NoError(t, err)
+	assert.Equal(t, "registry/second-svc", v)
+}
+
+func TestResolverCycles(t *testing.T) {
+	defaults, err := ParseProperties(`name=project
+a=$b$
+b=$c$
+c=$a$
+`)
+	require.NoError(t, err)
+	_, err = NewResolver(defaults, substitute)
+	require.Error(t, err)
+	assert.Equal(t, CycleErr{Keys: []string{"a", "b", "c", "a"}}, err)
+
+	_, err = NewResolver(Pairs{{K: "self", V: "$self$"}}, substitute)
+	assert.Equal(t, CycleErr{Keys: []string{"self", "self"}}, err)
+}
@@ -0,0 +1,153 @@
+package props
+
+import (
+	"os"
+	"strings"
+	"unicode"
+)
+
+// Source provides values for properties from a given origin, such as a file,
+// environment variables, or the command line.
+type Source interface {
+	// Name returns a human-readable description of the source, used to report
+	// where a value came from.
+	Name() string
+
+	// Load returns all values provided by the source. keys contains the names
+	// of properties provided by sources with lower precedence, and is used by
+	// sources unable to enumerate the values they provide.
+	Load(keys []string) (Pairs, error)
+}
+
+type staticSource struct {
+	name   string
+	values Pairs
+}
+
+func (s staticSource) Name() string {
+	return s.name
+}
+
+func (s staticSource) Load(_ []string) (Pairs, error) {
+	return s.values, nil
+}
+
+// NewStaticSource returns a Source providing a fixed set of values, such as
+// defaults declared by a template, or values provided through the command
+// line.
+func NewStaticSource(name string, values Pairs) Source {
+	return staticSource{name: name, values: values}
+}
+
+type fileSource struct {
+	path     string
+	optional bool
+}
+
+func (f fileSource) Name() string {
+	return f.path
+}
+
+func (f fileSource) Load(_ []string) (Pairs, error) {
+	values, err := ReadFile(f.path)
+	if err != nil && f.optional && os.IsNotExist(err) {
+		return nil, nil
+	}
+	return values, err
+}
+
+// NewFileSource returns a Source providing values contained in a file. The
+// file format is determined by its extension, as described by ReadFile. When
+// optional is true, a missing file is treated as an empty source.
+func NewFileSource(path string, optional bool) Source {
+	return fileSource{path: path, optional: optional}
+}
+
+type envSource struct {
+	prefix    string
+	lookupEnv func(string) (string, bool)
+}
+
+func (e envSource) Name() string {
+	return "environment"
+}
+
+func (e envSource) Load(keys []string) (Pairs, error) {
+	var result Pairs
+	for _, k := range keys {
+		if v, ok := e.lookupEnv(EnvName(e.prefix, k)); ok {
+			result = append(result, Pair{K: k, V: v})
+		}
+	}
+	return result, nil
+}
+
+// NewEnvSource returns a Source providing values from environment variables.
+// Variables are looked up for every known property, using the name returned
+// by EnvName for a given prefix.
+func NewEnvSource(prefix string) Source {
+	return envSource{prefix: prefix, lookupEnv: os.LookupEnv}
+}
+
+// EnvName returns the name of the environment variable holding the value of a
+// given property: the property name is converted to upper snake case and
+// appended to prefix. For instance, with a "G8_" prefix, `organization'
+// becomes G8_ORGANIZATION, and both `packageName' and `package-name' become
+// G8_PACKAGE_NAME.
+func EnvName(prefix, key string) string {
+	var b strings.Builder
+	b.WriteString(prefix)
+	var last rune
+	for i, chr := range key {
+		switch {
+		case unicode.IsUpper(chr) && i > 0 && (unicode.IsLower(last) || unicode.IsDigit(last)):
+			b.WriteRune('_')
+			b.WriteRune(chr)
+		case unicode.IsLetter(chr) || unicode.IsDigit(chr):
+			b.WriteRune(unicode.ToUpper(chr))
+		default:
+			b.WriteRune('_')
+		}
+		last = chr
+	}
+	return b.String()
+}
+
+// Layers combines multiple sources into a single set of values. Sources are
+// ordered by precedence: values provided by a source replace the ones
+// provided by sources preceding it.
+type Layers []Source
+
+// Resolved contains values produced by Layers, along with the name of the
+// source that provided each of them.
+type Resolved struct {
+	Values  *Map
+	origins map[string]string
+}
+
+// Origin returns the name of the source that provided the final value of a
+// given property, and true. Returns an empty string and false in case the
+// property is not present.
+func (r *Resolved) Origin(key string) (string, bool) {
+	o, ok := r.origins[key]
+	return o, ok
+}
+
+// Resolve loads all sources, returning the combined values and their origins.
+func (l Layers) Resolve() (*Resolved, error) {
+	result := &Resolved{
+		Values:  NewMap(nil),
+		origins: map[string]string{},
+	}
+	for _, s := range l {
+		values, err := s.Load(result.Values.Keys())
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range values {
+			result.Values.Set(p.K, p.V)
+			result.origins[p.K] = s.Name()
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,62 @@
+package props
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvName(t *testing.T) {
+	assert.Equal(t, "G8_ORGANIZATION", EnvName("G8_", "organization"))
+	assert.Equal(t, "G8_PACKAGE_NAME", EnvName("G8_", "packageName"))
+	assert.Equal(t, "G8_PACKAGE_NAME", EnvName("G8_", "package-name"))
+	assert.Equal(t, "G8_SCALA2_VERSION", EnvName("G8_", "scala2.version"))
+}
+
+func TestLayers(t *testing.T) {
+	dir := t.TempDir()
+	answers := filepath.Join(dir, "answers.properties")
+	require.NoError(t, os.WriteFile(answers, []byte("namespace=from-file\ncluster=from-file\n"), 0644))
+
+	env := envSource{prefix: "G8_", lookupEnv: func(name string) (string, bool) {
+		values := map[string]string{"G8_ORGANIZATION": "org.env", "G8_NAMESPACE": "from-env", "G8_UNKNOWN": "x"}
+		v, ok := values[name]
+		return v, ok
+	}}
+
+	layers := Layers{
+		NewStaticSource("template defaults", Pairs{{K: "name", V: "project"}, {K: "organization", V: "com.foo"}, {K: "namespace", V: "default"}}),
+		NewFileSource(filepath.Join(dir, "missing.properties"), true),
+		env,
+		NewFileSource(answers, false),
+		NewStaticSource("command line", Pairs{{K: "cluster", V: "from-cli"}}),
+	}
+	resolved, err := layers.Resolve()
+	require.NoError(t, err)
+	assert.Equal(t, Pairs{
+		{K: "name", V: "project"},
+		{K: "organization", V: "org.env"},
+		{K: "namespace", V: "from-file"},
+		{K: "cluster", V: "from-cli"},
+	}, resolved.Values.Pairs())
+
+	origins := map[string]string{
+		"name":         "template defaults",
+		"organization": "environment",
+		"namespace":    answers,
+		"cluster":      "command line",
+	}
+	for k, v := range origins {
+		o, ok := resolved.Origin(k)
+		assert.True(t, ok)
+		assert.Equal(t, v, o, k)
+	}
+	_, ok := resolved.Origin("unknown")
+	assert.False(t, ok)
+
+	_, err = Layers{NewFileSource(filepath.Join(dir, "missing.properties"), false)}.Resolve()
+	assert.Error(t, err)
+}
@@ -0,0 +1,109 @@
+package render
+
+import (
+	"errors"
+	"fmt"
+	iofs "io/fs"
+	"strings"
+
+	"github.com/gympass/go-giter8/fs"
+	"github.com/gympass/go-giter8/lexer"
+)
+
+// ConditionsFile is the name of a file, at the root of a template, listing
+// condition rules as described by ParseConditionRules. The file itself is
+// never part of the generated output.
+const ConditionsFile = ".g8conditions"
+
+// ConditionRule includes files and directories matching a glob pattern only
+// when a condition holds. Conditions use the same expressions as `$if(...)$'
+// tags, such as `docker.truthy' or `database.present', and may be negated
+// through a leading `!'. Patterns follow the semantics described by fs.Glob,
+// except they cannot be negated, and are matched against paths relative to
+// the template root.
+type ConditionRule struct {
+	Pattern   string
+	Condition string
+}
+
+// ParseConditionRules parses rules written one per line as `PATTERN
+// CONDITION', such as `docker/ docker.truthy', as used by ConditionsFile.
+// Empty lines and lines starting with `#' are ignored.
+func ParseConditionRules(s string) ([]ConditionRule, error) {
+	var rules []ConditionRule
+	for _, line := range strings.Split(s, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		} else if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid condition rule `%s': expected a pattern followed by a condition", strings.TrimSpace(line))
+		}
+		rules = append(rules, ConditionRule{Pattern: fields[0], Condition: fields[1]})
+	}
+	return rules, nil
+}
+
+type conditionMatcher struct {
+	pattern *fs.Glob
+	prop    string
+	helper  string
+	negate  bool
+}
+
+func compileConditionRules(rules []ConditionRule) ([]conditionMatcher, error) {
+	result := make([]conditionMatcher, 0, len(rules))
+	for _, r := range rules {
+		g, err := compileRulePattern(r.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid condition rule: %s", err)
+		}
+		m := conditionMatcher{pattern: g}
+		expr := r.Condition
+		if strings.HasPrefix(expr, "!") {
+			m.negate, expr = true, expr[1:]
+		}
+		idx := strings.Index(expr, ".")
+		if idx <= 0 {
+			return nil, fmt.Errorf("invalid condition `%s': expected a property followed by a helper, such as `%s.truthy'", r.Condition, expr)
+		}
+		m.prop, m.helper = expr[:idx], strings.ToLower(expr[idx+1:])
+		if m.helper != lexer.TRUTHY && m.helper != lexer.PRESENT {
+			return nil, fmt.Errorf("invalid condition `%s': unsupported helper `%s'", r.Condition, expr[idx+1:])
+		}
+		result = append(result, m)
+	}
+	return result, nil
+}
+
+// readConditionsFile reads rules listed by the ConditionsFile at the root of
+// fsys. Returns no rules in case the file does not exist.
+func readConditionsFile(fsys iofs.FS) ([]ConditionRule, error) {
+	data, err := iofs.ReadFile(fsys, ConditionsFile)
+	if errors.Is(err, iofs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	rules, err := ParseConditionRules(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s: %s", ConditionsFile, err)
+	}
+	return rules, nil
+}
+
+// included indicates whether conditions of all rules matching source hold
+func (r *renderer) included(source string, isDir bool) (bool, error) {
+	for _, m := range r.conds {
+		if !m.pattern.Match(source, isDir) {
+			continue
+		}
+		ok, err := r.exec.evaluateConditionalExpression(m.prop, m.helper)
+		if err != nil {
+			return false, err
+		}
+		if ok == m.negate {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,89 @@
+package render
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	gfs "github.com/gympass/go-giter8/fs"
+	"github.com/gympass/go-giter8/props"
+)
+
+func TestParseConditionRules(t *testing.T) {
+	rules, err := ParseConditionRules("# Optional features\ndocker/ docker.truthy\n\n*.sql !database.present\n")
+	require.NoError(t, err)
+	assert.Equal(t, []ConditionRule{
+		{Pattern: "docker/", Condition: "docker.truthy"},
+		{Pattern: "*.sql", Condition: "!database.present"},
+	}, rules)
+
+	_, err = ParseConditionRules("docker/")
+	assert.EqualError(t, err, "invalid condition rule `docker/': expected a pattern followed by a condition")
+}
+
+func TestCompileConditionRules(t *testing.T) {
+	_, err := compileConditionRules([]ConditionRule{{Pattern: "docker/", Condition: "docker"}})
+	assert.EqualError(t, err, "invalid condition `docker': expected a property followed by a helper, such as `docker.truthy'")
+	_, err = compileConditionRules([]ConditionRule{{Pattern: "docker/", Condition: "docker.falsy"}})
+	assert.EqualError(t, err, "invalid condition `docker.falsy': unsupported helper `falsy'")
+	_, err = compileConditionRules([]ConditionRule{{Pattern: "[docker", Condition: "docker.truthy"}})
+	assert.EqualError(t, err, "invalid condition rule: invalid pattern `[docker': unterminated character class")
+	_, err = compileConditionRules([]ConditionRule{{Pattern: "!local.env", Condition: "docker.truthy"}})
+	assert.EqualError(t, err, "invalid condition rule: invalid pattern `!local.env': negated patterns are not supported here")
+}
+
+func conditionsFS() fstest.MapFS {
+	return fstest.MapFS{
+		ConditionsFile:          {Data: []byte("docker/ docker.truthy\n.dockerignore docker.truthy\nlocal.env !docker.truthy\n")},
+		"README.md":             {Data: []byte("$name$\n")},
+		"local.env":             {Data: []byte("NAME=$name$\n")},
+		".dockerignore":         {Data: []byte("*.log\n")},
+		"docker/Dockerfile":     {Data: []byte("FROM scratch\n")},
+		"docker/compose/db.yml": {Data: []byte("db: {}\n")},
+	}
+}
+
+func TestTemplateOutputConditions(t *testing.T) {
+	out := gfs.NewMemOutput()
+	p := props.Pairs{{K: "name", V: "Foo"}, {K: "docker", V: "yes"}}
+	require.NoError(t, TemplateOutput(p, conditionsFS(), out, nil))
+	assert.Equal(t, []string{".dockerignore", "README.md", "docker", "docker/Dockerfile", "docker/compose", "docker/compose/db.yml"}, out.Names())
+
+	out = gfs.NewMemOutput()
+	p = props.Pairs{{K: "name", V: "Foo"}, {K: "docker", V: "no"}}
+	require.NoError(t, TemplateOutput(p, conditionsFS(), out, nil))
+	assert.Equal(t, []string{"README.md", "local.env"}, out.Names())
+
+	out = gfs.NewMemOutput()
+	p = props.Pairs{{K: "name", V: "Foo"}, {K: "docker", V: "yes"}, {K: "readme", V: ""}}
+	opts := &Options{Conditions: []ConditionRule{{Pattern: "/README.md", Condition: "readme.present"}}}
+	require.NoError(t, TemplateOutput(p, conditionsFS(), out, opts))
+	assert.NotContains(t, out.Names(), "README.md")
+	assert.Contains(t, out.Names(), "docker/Dockerfile")
+}
+
+func TestPlanConditions(t *testing.T) {
+	p := props.Pairs{{K: "name", V: "Foo"}}
+	plan, err := PlanFS(p, conditionsFS(), nil)
+	require.NoError(t, err)
+	actions := map[string]Action{}
+	for _, e := range plan {
+		actions[e.Source] = e.Action
+	}
+	assert.Equal(t, map[string]Action{
+		".dockerignore":         ActionSkip,
+		"README.md":             ActionTemplate,
+		"docker":                ActionSkip,
+		"docker/Dockerfile":     ActionSkip,
+		"docker/compose":        ActionSkip,
+		"docker/compose/db.yml": ActionSkip,
+		"local.env":             ActionTemplate,
+	}, actions)
+
+	fsys := conditionsFS()
+	fsys[ConditionsFile] = &fstest.MapFile{Data: []byte("docker/\n")}
+	_, err = PlanFS(p, fsys, nil)
+	assert.EqualError(t, err, "error parsing .g8conditions: invalid condition rule `docker/': expected a pattern followed by a condition")
+}
@@ -0,0 +1,121 @@
+package render
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/gympass/go-giter8/fs"
+)
+
+// ConflictPolicy determines how rendering proceeds when a file being
+// generated already exists in the destination.
+type ConflictPolicy int
+
+const (
+	// ConflictFail aborts rendering with an error. This is the default
+	// policy.
+	ConflictFail ConflictPolicy = iota
+	// ConflictSkip keeps the existing file, discarding the generated one.
+	ConflictSkip
+	// ConflictOverwrite replaces the existing file with the generated one.
+	ConflictOverwrite
+	// ConflictKeepBoth keeps the existing file, and writes the generated one
+	// next to it, using the same name followed by KeepBothSuffix. In case
+	// that name is taken as well, a counter is appended to it.
+	ConflictKeepBoth
+	// ConflictAsk invokes Options.ConflictHandler for each conflicting file,
+	// which decides which of the other policies to apply.
+	ConflictAsk
+)
+
+// KeepBothSuffix is appended to names of generated files conflicting with
+// existing ones when using ConflictKeepBoth.
+const KeepBothSuffix = ".g8new"
+
+// maxKeepBothAttempts limits how many names ConflictKeepBoth tries
+const maxKeepBothAttempts = 100
+
+var conflictPolicyNames = map[ConflictPolicy]string{
+	ConflictFail:      "fail",
+	ConflictSkip:      "skip",
+	ConflictOverwrite: "overwrite",
+	ConflictKeepBoth:  "keep-both",
+	ConflictAsk:       "ask",
+}
+
+func (c ConflictPolicy) String() string {
+	if n, ok := conflictPolicyNames[c]; ok {
+		return n
+	}
+	return fmt.Sprintf("ConflictPolicy(%d)", int(c))
+}
+
+// ParseConflictPolicy returns the ConflictPolicy with a given name: either
+// `fail', `skip', `overwrite', `keep-both' or `ask'.
+func ParseConflictPolicy(name string) (ConflictPolicy, error) {
+	for p, n := range conflictPolicyNames {
+		if n == name {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown conflict policy `%s'", name)
+}
+
+// ConflictHandler is invoked when using ConflictAsk to decide how to handle a
+// generated file conflicting with an existing one at path. It must return a
+// policy other than ConflictAsk.
+type ConflictHandler func(path string) (ConflictPolicy, error)
+
+// resolveConflict returns the path a generated file must be written to, or an
+// empty string in case it must be skipped.
+func resolveConflict(out fs.Output, dest string, opts *Options) (string, error) {
+	if _, err := out.Stat(dest); err != nil {
+		if os.IsNotExist(err) {
+			return dest, nil
+		}
+		return "", err
+	}
+
+	policy := ConflictFail
+	if opts != nil {
+		policy = opts.OnConflict
+	}
+	if policy == ConflictAsk {
+		if opts.ConflictHandler == nil {
+			return "", fmt.Errorf("%s: destination file already exists, and no ConflictHandler was provided", dest)
+		}
+		var err error
+		if policy, err = opts.ConflictHandler(dest); err != nil {
+			return "", err
+		}
+	}
+
+	switch policy {
+	case ConflictFail:
+		return "", fmt.Errorf("%s: destination file already exists", dest)
+	case ConflictSkip:
+		return "", nil
+	case ConflictOverwrite:
+		return dest, nil
+	case ConflictKeepBoth:
+		return keepBothName(out, dest)
+	}
+	return "", fmt.Errorf("%s: invalid conflict policy %s", dest, policy)
+}
+
+// keepBothName returns the first name derived from dest and KeepBothSuffix,
+// such as `README.md.g8new' or `README.md.g8new.1', not present in out.
+func keepBothName(out fs.Output, dest string) (string, error) {
+	for i := 0; i <= maxKeepBothAttempts; i++ {
+		name := dest + KeepBothSuffix
+		if i > 0 {
+			name = fmt.Sprintf("%s.%d", name, i)
+		}
+		if _, err := out.Stat(name); os.IsNotExist(err) {
+			return name, nil
+		} else if err != nil {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("%s: destination file already exists, and no name is available to keep both", dest)
+}
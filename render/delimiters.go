@@ -0,0 +1,92 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gympass/go-giter8/fs"
+	"github.com/gympass/go-giter8/lexer"
+)
+
+// DelimiterRule sets the delimiters used by template files matching a given
+// glob pattern, such as `*.sh' or `charts/**'. Patterns follow the semantics
+// described by fs.Glob, except they cannot be negated, and are matched
+// against paths relative to the template root.
+type DelimiterRule struct {
+	Pattern    string
+	Delimiters lexer.Delimiters
+}
+
+// ParseDelimiterRules parses rules written as a comma-separated list of
+// `PATTERN OPEN CLOSE' entries, such as `*.sh @@ @@, Makefile {{ }}', as
+// used by the `delimiters' property.
+func ParseDelimiterRules(s string) ([]DelimiterRule, error) {
+	var rules []DelimiterRule
+	for _, entry := range strings.Split(s, ",") {
+		fields := strings.Fields(entry)
+		if len(fields) == 0 {
+			continue
+		} else if len(fields) != 3 {
+			return nil, fmt.Errorf("invalid delimiter rule `%s': expected a pattern followed by an opening and a closing delimiter", strings.TrimSpace(entry))
+		}
+		rule := DelimiterRule{Pattern: fields[0], Delimiters: lexer.Delimiters{Open: fields[1], Close: fields[2]}}
+		if err := rule.Delimiters.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid delimiter rule `%s': %s", strings.TrimSpace(entry), err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+type delimiterMatcher struct {
+	pattern    *fs.Glob
+	delimiters lexer.Delimiters
+}
+
+// compileRulePattern compiles the pattern of a rule applying to matching
+// files, such as a DelimiterRule or a ConditionRule. Unlike lists of patterns,
+// a single rule cannot be negated.
+func compileRulePattern(pattern string) (*fs.Glob, error) {
+	g, err := fs.CompileGlob(pattern)
+	if err != nil {
+		return nil, err
+	} else if g.Negated() {
+		return nil, fmt.Errorf("invalid pattern `%s': negated patterns are not supported here", pattern)
+	}
+	return g, nil
+}
+
+func compileDelimiterRules(rules []DelimiterRule) ([]delimiterMatcher, error) {
+	result := make([]delimiterMatcher, 0, len(rules))
+	for _, r := range rules {
+		if err := r.Delimiters.Validate(); err != nil {
+			return nil, err
+		}
+		g, err := compileRulePattern(r.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid delimiter rule: %s", err)
+		}
+		result = append(result, delimiterMatcher{pattern: g, delimiters: r.Delimiters})
+	}
+	return result, nil
+}
+
+// tokenize parses the contents of a template file. Delimiters are set by the
+// last rule matching source, unless the file contains a header directive
+// described by lexer.ExtractDelimiters.
+func (r *renderer) tokenize(source, contents string) (lexer.AST, error) {
+	d := lexer.DefaultDelimiters
+	for _, m := range r.delims {
+		if m.pattern.Match(source, false) {
+			d = m.delimiters
+		}
+	}
+
+	header, rest, ok, err := lexer.ExtractDelimiters(contents)
+	if err != nil {
+		return nil, err
+	} else if ok {
+		d, contents = header, rest
+	}
+	return lexer.TokenizeDelimiters(contents, d)
+}
@@ -0,0 +1,123 @@
+package render
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/gympass/go-giter8/props"
+)
+
+// EventKind identifies what happened to a template item during rendering
+type EventKind int
+
+const (
+	// EventWriting is emitted right before a file is written. By then, the
+	// file was already rendered, possibly much earlier by another worker,
+	// and Options.BeforeRender and Options.AfterRender were called; its
+	// Destination reflects changes made by those callbacks.
+	EventWriting EventKind = iota
+	// EventDirectoryCreated is emitted after a directory is created
+	EventDirectoryCreated
+	// EventRendered is emitted after a file is rendered as a template
+	EventRendered
+	// EventCopied is emitted after a file is copied as-is, either for matching
+	// the `verbatim' property or for being binary
+	EventCopied
+	// EventSkipped is emitted for items that are not written, either for
+	// having an empty path, being skipped by a callback, or conflicting with
+	// an existing file under ConflictSkip
+	EventSkipped
+	// EventError is emitted when handling an item fails. Rendering stops
+	// right after it.
+	EventError
+	// EventSymlinked is emitted after a symbolic link is created, under
+	// fs.SymlinkPreserve
+	EventSymlinked
+)
+
+var eventKindNames = map[EventKind]string{
+	EventWriting:          "writing",
+	EventDirectoryCreated: "directory",
+	EventRendered:         "rendered",
+	EventCopied:           "copied",
+	EventSkipped:          "skipped",
+	EventError:            "error",
+	EventSymlinked:        "symlinked",
+}
+
+func (k EventKind) String() string {
+	if n, ok := eventKindNames[k]; ok {
+		return n
+	}
+	return fmt.Sprintf("EventKind(%d)", int(k))
+}
+
+// Event describes progress made while rendering a template
+type Event struct {
+	Kind EventKind
+	// Source contains the path of the item within the template
+	Source string
+	// Destination contains the path the item is written to, relative to the
+	// output's root. Empty for items skipped due to an empty path.
+	Destination string
+	// Err contains the error that stopped rendering, for EventError
+	Err error
+	// Done contains how many items of the template were handled so far,
+	// including the current one once it is complete, and Total how many items
+	// the template contains.
+	Done, Total int
+}
+
+// EventHandler receives events emitted while rendering a template. Handlers
+// are never called concurrently, and events are emitted in the same order
+// items are written.
+type EventHandler func(e Event)
+
+// FileContext describes a file being rendered or copied, and is provided to
+// callbacks set through Options. Callbacks may change Destination, Skip and,
+// for files rendered as templates, Contents, to change how the file is
+// written.
+type FileContext struct {
+	// Source contains the path of the file within the template
+	Source string
+	// Destination contains the path the file is written to, relative to the
+	// output's root
+	Destination string
+	// Info describes the source file
+	Info os.FileInfo
+	// Props contains properties used to render the template. Pairs returned
+	// by it are copies, so changing them affects neither the template nor
+	// other files, which may be handled concurrently.
+	Props props.Lookup
+	// Action determines how the file is handled
+	Action Action
+	// Contents contains the rendered file, or the rendered target of a
+	// symbolic link under fs.SymlinkPreserve, and is only populated by the
+	// time AfterRender is called.
+	Contents string
+	// Skip prevents the file from being written when set
+	Skip bool
+}
+
+// readOnlyProps exposes properties to callbacks, preventing them from changing
+// values shared by all files through pairs returned by FetchPair.
+type readOnlyProps struct {
+	props props.Lookup
+}
+
+func (r readOnlyProps) Fetch(name string) (string, bool) {
+	return r.props.Fetch(name)
+}
+
+func (r readOnlyProps) FetchPair(name string) (*props.Pair, bool) {
+	pair, ok := r.props.FetchPair(name)
+	if !ok {
+		return nil, false
+	}
+	c := *pair
+	return &c, true
+}
+
+// FileCallback is called with information about a file being rendered or
+// copied. Returning an error stops rendering.
+type FileCallback func(f *FileContext) error
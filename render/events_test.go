@@ -0,0 +1,107 @@
+package render
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	gfs "github.com/gympass/go-giter8/fs"
+	"github.com/gympass/go-giter8/props"
+)
+
+func TestTemplateOutputEvents(t *testing.T) {
+	p := props.Pairs{{K: "name", V: "Foo"}, {K: "verbatim", V: "*.html"}, {K: "docker", V: "no"}}
+	var events []string
+	var last Event
+	opts := &Options{OnEvent: func(e Event) {
+		events = append(events, e.Kind.String()+" "+e.Destination)
+		last = e
+	}}
+	require.NoError(t, TemplateOutput(p, templateFS(), gfs.NewMemOutput(), opts))
+	assert.Equal(t, []string{
+		"skipped ",
+		"directory Foo",
+		"writing Foo/README.md",
+		"rendered Foo/README.md",
+		"directory Foo/docs",
+		"writing Foo/docs/index.html",
+		"copied Foo/docs/index.html",
+		"writing Foo/run.sh",
+		"rendered Foo/run.sh",
+		"directory Foo/static",
+		"writing Foo/static/logo.bin",
+		"copied Foo/static/logo.bin",
+	}, events)
+	assert.Equal(t, 8, last.Done)
+	assert.Equal(t, 8, last.Total)
+
+	events = nil
+	out := gfs.NewMemOutput()
+	require.NoError(t, gfs.WriteFile(out, "Foo/README.md", []byte("existing\n"), 0644))
+	err := TemplateOutput(p, templateFS(), out, opts)
+	require.Error(t, err)
+	assert.Equal(t, EventError, last.Kind)
+	assert.Equal(t, "$name$/README.md", last.Source)
+	assert.Equal(t, err, last.Err)
+}
+
+func TestTemplateOutputCallbacks(t *testing.T) {
+	p := props.Pairs{{K: "name", V: "Foo"}, {K: "verbatim", V: "*.html"}, {K: "docker", V: "no"}}
+	var copied, writing []string
+	opts := &Options{
+		BeforeRender: func(f *FileContext) error {
+			switch f.Source {
+			case "$name$/run.sh":
+				f.Skip = true
+			case "$name$/README.md":
+				name, _ := f.Props.Fetch("name")
+				f.Destination = "Foo/README-" + name + ".md"
+				// Props cannot be changed by callbacks
+				pair, ok := f.Props.FetchPair("name")
+				require.True(t, ok)
+				pair.V = "Bar"
+			}
+			return nil
+		},
+		OnEvent: func(e Event) {
+			if e.Kind == EventWriting {
+				writing = append(writing, e.Destination)
+			}
+		},
+		AfterRender: func(f *FileContext) error {
+			assert.Equal(t, ActionTemplate, f.Action)
+			f.Contents = strings.ToUpper(f.Contents)
+			return nil
+		},
+		AfterCopy: func(f *FileContext) error {
+			copied = append(copied, f.Destination)
+			return nil
+		},
+	}
+	out := gfs.NewMemOutput()
+	require.NoError(t, TemplateOutput(p, templateFS(), out, opts))
+
+	assert.Equal(t, []string{
+		"Foo",
+		"Foo/README-Foo.md",
+		"Foo/docs",
+		"Foo/docs/index.html",
+		"Foo/static",
+		"Foo/static/logo.bin",
+	}, out.Names())
+	contents, err := out.ReadFile("Foo/README-Foo.md")
+	require.NoError(t, err)
+	assert.Equal(t, "HELLO, FOO!\n", string(contents))
+	assert.Equal(t, []string{"Foo/docs/index.html", "Foo/static/logo.bin"}, copied)
+	assert.Equal(t, []string{"Foo/README-Foo.md", "Foo/docs/index.html", "Foo/run.sh", "Foo/static/logo.bin"}, writing)
+	value, _ := p.Fetch("name")
+	assert.Equal(t, "Foo", value)
+
+	opts = &Options{BeforeRender: func(f *FileContext) error {
+		return errors.New("boom")
+	}}
+	assert.EqualError(t, TemplateOutput(p, templateFS(), gfs.NewMemOutput(), opts), "boom")
+}
@@ -1,10 +1,12 @@
 package render
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 	"unsafe"
 
@@ -17,6 +19,9 @@ var wordSpaceRegexp = regexp.MustCompile(`[^a-zA-Z0-9]`)
 var snakeCaseRegexp = regexp.MustCompile(`[\s.]`)
 var src = rand.NewSource(time.Now().UnixNano())
 
+// srcMu guards src, as rand.Source is not safe for concurrent use
+var srcMu sync.Mutex
+
 const letterBytes = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
 const (
 	letterIdxBits = 6
@@ -88,6 +93,8 @@ func packageDir(val string) string {
 func generateRandom(val string) string {
 	const n = 40
 	b := make([]byte, n)
+	srcMu.Lock()
+	defer srcMu.Unlock()
 	for i, cache, remain := n-1, src.Int63(), letterIdxMax; i >= 0; {
 		if remain == 0 {
 			cache, remain = src.Int63(), letterIdxMax
@@ -159,7 +166,7 @@ func extractFormatOptions(template *lexer.Template) []string {
 }
 
 type Executor struct {
-	props props.Pairs
+	props props.Lookup
 }
 
 func (e *Executor) runMethods(t *lexer.Template) (string, error) {
@@ -192,27 +199,36 @@ func (e *Executor) evaluateConditionalExpression(expr, helper string) (bool, err
 	panic("BUG: helper allowed by lexer, but not implemented by renderer")
 }
 
-func (e *Executor) evaluateConditional(c *lexer.Conditional, r *strings.Builder) error {
+func (e *Executor) evaluateConditional(ctx context.Context, c *lexer.Conditional, r *strings.Builder) error {
 	ok, err := e.evaluateConditionalExpression(c.Property, c.Helper)
 	if err != nil {
 		return err
 	} else if ok {
-		return e.execTree(c.Then, r)
+		return e.execTree(ctx, c.Then, r)
 	}
 
 	for _, c := range c.ElseIf {
-		return e.evaluateConditional(c, r)
+		return e.evaluateConditional(ctx, c, r)
 	}
 
 	if c.Else != nil {
-		return e.execTree(c.Else, r)
+		return e.execTree(ctx, c.Else, r)
 	}
 
 	return nil
 }
 
-func (e *Executor) execTree(tree lexer.AST, r *strings.Builder) error {
-	for _, elem := range tree {
+// cancelCheckInterval determines how many nodes are rendered between checks
+// for context cancellation
+const cancelCheckInterval = 256
+
+func (e *Executor) execTree(ctx context.Context, tree lexer.AST, r *strings.Builder) error {
+	for i, elem := range tree {
+		if i%cancelCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
 		switch v := elem.(type) {
 		case *lexer.Literal:
 			r.WriteString(v.String)
@@ -223,7 +239,7 @@ func (e *Executor) execTree(tree lexer.AST, r *strings.Builder) error {
 			}
 			r.WriteString(val)
 		case *lexer.Conditional:
-			if err := e.evaluateConditional(v, r); err != nil {
+			if err := e.evaluateConditional(ctx, v, r); err != nil {
 				return err
 			}
 		}
@@ -234,16 +250,31 @@ func (e *Executor) execTree(tree lexer.AST, r *strings.Builder) error {
 // Exec takes a given AST and renders using props passed to the current
 // Executor. Either returns a rendered string, or an error.
 func (e *Executor) Exec(tree lexer.AST) (string, error) {
+	return e.ExecContext(context.Background(), tree)
+}
+
+// ExecContext works like Exec, but stops rendering and returns ctx.Err() in
+// case ctx is done before the whole AST is rendered.
+func (e *Executor) ExecContext(ctx context.Context, tree lexer.AST) (string, error) {
 	var result strings.Builder
-	if err := e.execTree(tree, &result); err != nil {
+	if err := e.execTree(ctx, tree, &result); err != nil {
 		return "", err
 	}
 	return result.String(), nil
 }
 
-// NewExecutor returns a new Executor using provided props.Pairs
-func NewExecutor(props props.Pairs) *Executor {
+// NewExecutor returns a new Executor using provided properties, which may
+// be either a props.Pairs slice or a *props.Map. A props.Pairs slice is
+// indexed into a props.Map, so lookups performed during rendering take
+// constant time.
+func NewExecutor(p props.Lookup) *Executor {
+	switch v := p.(type) {
+	case nil:
+		p = props.NewMap(nil)
+	case props.Pairs:
+		p = props.NewMap(v)
+	}
 	return &Executor{
-		props: props,
+		props: p,
 	}
 }
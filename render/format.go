@@ -0,0 +1,127 @@
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"go/format"
+	"path"
+	"strings"
+)
+
+// Formatter post-processes the contents of a rendered file, returning the
+// formatted contents.
+type Formatter func(contents string) (string, error)
+
+// Formatters maps file names or extensions to the Formatter applied to
+// rendered files matching them. Keys are either a file name, such as
+// `Makefile', or an extension including its leading dot, such as `.go'.
+// Extensions are matched regardless of case, and file names take precedence
+// over extensions.
+type Formatters map[string]Formatter
+
+// find returns the Formatter applied to a file with a given name
+func (f Formatters) find(name string) (Formatter, bool) {
+	base := path.Base(name)
+	if fn, ok := f[base]; ok {
+		return fn, true
+	}
+	ext := strings.ToLower(path.Ext(base))
+	if ext == "" {
+		return nil, false
+	}
+	fn, ok := f[ext]
+	return fn, ok
+}
+
+// FormatGo formats Go source code the same way gofmt does
+func FormatGo(contents string) (string, error) {
+	res, err := format.Source([]byte(contents))
+	if err != nil {
+		return "", err
+	}
+	return string(res), nil
+}
+
+// FormatJSON indents JSON documents using two spaces, ending them with a
+// newline.
+func FormatJSON(contents string) (string, error) {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, []byte(contents), "", "  "); err != nil {
+		return "", err
+	}
+	buf.WriteByte('\n')
+	return buf.String(), nil
+}
+
+// FormatWhitespace removes trailing whitespace from every line, collapses
+// consecutive blank lines into a single one, removes leading and trailing
+// blank lines, and ends non-empty contents with a single newline. Whitespace
+// escaped by a backslash is kept. As blank lines are not preserved, it is not
+// suitable for formats where they are meaningful, such as YAML block scalars.
+func FormatWhitespace(contents string) (string, error) {
+	lines := strings.Split(contents, "\n")
+	var b strings.Builder
+	blank := 0
+	for _, l := range lines {
+		l = trimTrailingWhitespace(l)
+		if l == "" {
+			blank++
+			continue
+		}
+		if b.Len() > 0 && blank > 0 {
+			b.WriteByte('\n')
+		}
+		blank = 0
+		b.WriteString(l)
+		b.WriteByte('\n')
+	}
+	return b.String(), nil
+}
+
+// trimTrailingWhitespace removes trailing whitespace from l, stopping at
+// whitespace escaped by an odd amount of backslashes.
+func trimTrailingWhitespace(l string) string {
+	end := len(l)
+	for end > 0 && strings.IndexByte(" \t\r", l[end-1]) >= 0 {
+		backslashes := 0
+		for i := end - 2; i >= 0 && l[i] == '\\'; i-- {
+			backslashes++
+		}
+		if backslashes%2 == 1 {
+			break
+		}
+		end--
+	}
+	return l[:end]
+}
+
+// ChainFormatters returns a Formatter applying all given formatters in order
+func ChainFormatters(formatters ...Formatter) Formatter {
+	return func(contents string) (string, error) {
+		var err error
+		for _, f := range formatters {
+			if contents, err = f(contents); err != nil {
+				return "", err
+			}
+		}
+		return contents, nil
+	}
+}
+
+// DefaultFormatters returns the built-in formatters: Go files are formatted
+// through FormatGo, JSON files through FormatJSON, and FormatWhitespace is
+// applied to Markdown, text, shell, Makefile, TOML and `.properties' files.
+// YAML files are left untouched, as blank lines are significant within block
+// scalars.
+func DefaultFormatters() Formatters {
+	return Formatters{
+		".go":         FormatGo,
+		".json":       FormatJSON,
+		".md":         FormatWhitespace,
+		".txt":        FormatWhitespace,
+		".sh":         FormatWhitespace,
+		"Makefile":    FormatWhitespace,
+		".properties": FormatWhitespace,
+		".toml":       FormatWhitespace,
+	}
+}
@@ -0,0 +1,92 @@
+package render
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	gfs "github.com/gympass/go-giter8/fs"
+	"github.com/gympass/go-giter8/props"
+)
+
+func TestFormatWhitespace(t *testing.T) {
+	for _, c := range []struct{ in, out string }{
+		{"", ""},
+		{"\n\n", ""},
+		{"a", "a\n"},
+		{"a  \nb\t\n", "a\nb\n"},
+		{"\n\na\n\n\n\nb\n\n\n", "a\n\nb\n"},
+		{"a\r\n\r\nb\r\n", "a\n\nb\n"},
+		{"key=value\\ \nnext=1\n", "key=value\\ \nnext=1\n"},
+		{"key=value\\   \n", "key=value\\ \n"},
+		{"echo a \\\t\n", "echo a \\\t\n"},
+		{"path=C:\\\\  \n", "path=C:\\\\\n"},
+	} {
+		res, err := FormatWhitespace(c.in)
+		require.NoError(t, err)
+		assert.Equal(t, c.out, res, "input: %q", c.in)
+	}
+}
+
+func TestFormatters(t *testing.T) {
+	res, err := FormatGo("package foo\nimport \"fmt\"\nfunc  a( ) { fmt.Println( ) }\n")
+	require.NoError(t, err)
+	assert.Equal(t, "package foo\n\nimport \"fmt\"\n\nfunc a() { fmt.Println() }\n", res)
+	_, err = FormatGo("package foo\nfunc {")
+	assert.Error(t, err)
+
+	res, err = FormatJSON(`{"a":[1,2],"b":{}}`)
+	require.NoError(t, err)
+	assert.Equal(t, "{\n  \"a\": [\n    1,\n    2\n  ],\n  \"b\": {}\n}\n", res)
+
+	f := Formatters{"Makefile": FormatWhitespace, ".go": FormatGo}
+	_, ok := f.find("src/Makefile")
+	assert.True(t, ok)
+	_, ok = f.find("src/MAIN.GO")
+	assert.True(t, ok)
+	_, ok = f.find("src/main.json")
+	assert.False(t, ok)
+	_, ok = f.find("LICENSE")
+	assert.False(t, ok)
+
+	for _, name := range []string{"a.md", "a.txt", "a.sh", "Makefile", "a.properties", "a.toml"} {
+		_, ok = DefaultFormatters().find(name)
+		assert.True(t, ok, name)
+	}
+	// Blank lines may be significant in YAML block scalars
+	for _, name := range []string{"a.yml", "a.yaml"} {
+		_, ok = DefaultFormatters().find(name)
+		assert.False(t, ok, name)
+	}
+}
+
+func TestTemplateOutputFormatters(t *testing.T) {
+	fsys := fstest.MapFS{
+		"main.go":        {Data: []byte("package main\n\nimport (\n$if(log.truthy)$\t\"log\"\n$endif$\t\"fmt\"\n)\n\nfunc main() {\n$if(log.truthy)$log.Println(\"hi\")\n$endif$fmt.Println(\"$name$\")\n}\n")},
+		"conf.json":      {Data: []byte(`{"name":"$name$"}`)},
+		"ci.yml":         {Data: []byte("name: $name$   \n\n\n\nsteps: []\n")},
+		"README.md":      {Data: []byte("# $name$  \n\n\n\nDocs\n\n")},
+		"app.properties": {Data: []byte("name=$name$   \nsuffix=-\\ \n")},
+	}
+	p := props.Pairs{{K: "name", V: "Foo"}, {K: "log", V: "yes"}}
+	out := gfs.NewMemOutput()
+	require.NoError(t, TemplateOutput(p, fsys, out, &Options{Formatters: DefaultFormatters()}))
+
+	read := func(name string) string {
+		data, err := out.ReadFile(name)
+		require.NoError(t, err)
+		return string(data)
+	}
+	assert.Equal(t, "package main\n\nimport (\n\t\"fmt\"\n\t\"log\"\n)\n\nfunc main() {\n\tlog.Println(\"hi\")\n\tfmt.Println(\"Foo\")\n}\n", read("main.go"))
+	assert.Equal(t, "{\n  \"name\": \"Foo\"\n}\n", read("conf.json"))
+	assert.Equal(t, "name: Foo   \n\n\n\nsteps: []\n", read("ci.yml"))
+	assert.Equal(t, "# Foo\n\nDocs\n", read("README.md"))
+	assert.Equal(t, "name=Foo\nsuffix=-\\ \n", read("app.properties"))
+
+	fsys["broken.go"] = &fstest.MapFile{Data: []byte("package $name$\nfunc {\n")}
+	err := TemplateOutput(p, fsys, gfs.NewMemOutput(), &Options{Formatters: DefaultFormatters()})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "error formatting broken.go")
+}
@@ -0,0 +1,188 @@
+package render
+
+import (
+	"context"
+	"fmt"
+	iofs "io/fs"
+	"strings"
+
+	"github.com/gympass/go-giter8/fs"
+	"github.com/gympass/go-giter8/props"
+)
+
+// Action describes how a template item is handled during rendering
+type Action int
+
+const (
+	// ActionDirectory creates a directory
+	ActionDirectory Action = iota
+	// ActionTemplate renders a text file as a template
+	ActionTemplate
+	// ActionVerbatim copies a file matching the `verbatim' property as-is
+	ActionVerbatim
+	// ActionBinary copies a binary file as-is
+	ActionBinary
+	// ActionSkip ignores an item whose path rendered to an empty string, whose
+	// conditions do not hold, or which is a symbolic link under
+	// fs.SymlinkSkip
+	ActionSkip
+	// ActionSymlink creates a symbolic link whose target is rendered as a
+	// template, under fs.SymlinkPreserve
+	ActionSymlink
+)
+
+var actionNames = map[Action]string{
+	ActionDirectory: "directory",
+	ActionTemplate:  "template",
+	ActionVerbatim:  "verbatim",
+	ActionBinary:    "binary",
+	ActionSkip:      "skip",
+	ActionSymlink:   "symlink",
+}
+
+func (a Action) String() string {
+	if n, ok := actionNames[a]; ok {
+		return n
+	}
+	return fmt.Sprintf("Action(%d)", int(a))
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (a Action) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// PlanEntry describes how a single template item is handled during
+// rendering.
+type PlanEntry struct {
+	// Source contains the path of the item within the template
+	Source string `json:"source"`
+	// Destination contains the rendered path of the item, relative to the
+	// destination root. Empty for skipped items.
+	Destination string `json:"destination,omitempty"`
+	// Action determines how the item is handled
+	Action Action `json:"action"`
+}
+
+// Plan lists all items of a template, along with how each of them is handled
+// during rendering, in the order they are processed.
+type Plan []PlanEntry
+
+type renderer struct {
+	ctx    context.Context
+	fsys   iofs.FS
+	exec   *Executor
+	opts   *Options
+	verbs  fs.GlobSet
+	scan   fs.ScanOptions
+	delims []delimiterMatcher
+	conds  []conditionMatcher
+}
+
+func newRenderer(ctx context.Context, props props.Lookup, fsys iofs.FS, opts *Options) (*renderer, error) {
+	r := &renderer{
+		ctx:  ctx,
+		fsys: fsys,
+		exec: NewExecutor(props),
+		opts: opts,
+	}
+	var err error
+	if verb, ok := props.Fetch("verbatim"); ok {
+		if r.verbs, err = fs.CompileGlobSet(strings.Fields(verb)); err != nil {
+			return nil, fmt.Errorf("invalid verbatim property: %s", err)
+		}
+	}
+	if exclude, ok := props.Fetch("exclude"); ok {
+		if r.scan.Exclude, err = fs.CompileGlobSet(strings.Fields(exclude)); err != nil {
+			return nil, fmt.Errorf("invalid exclude property: %s", err)
+		}
+	}
+
+	var rules []DelimiterRule
+	if opts != nil {
+		rules = append(rules, opts.Delimiters...)
+	}
+	if v, ok := props.Fetch("delimiters"); ok {
+		propRules, err := ParseDelimiterRules(v)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, propRules...)
+	}
+	if r.delims, err = compileDelimiterRules(rules); err != nil {
+		return nil, err
+	}
+
+	var conds []ConditionRule
+	if opts != nil {
+		conds = append(conds, opts.Conditions...)
+	}
+	fileConds, err := readConditionsFile(fsys)
+	if err != nil {
+		return nil, err
+	}
+	conds = append(conds, fileConds...)
+	if r.conds, err = compileConditionRules(conds); err != nil {
+		return nil, err
+	}
+	r.scan.Exclude = append(r.scan.Exclude, fs.MustCompileGlob("/"+ConditionsFile))
+	if opts != nil {
+		r.scan.Symlinks = opts.Symlinks
+	}
+	return r, nil
+}
+
+func (r *renderer) plan() (Plan, error) {
+	items, err := fs.ScanFSOpts(r.fsys, &r.scan)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(Plan, 0, len(items))
+	for _, item := range items {
+		if err = r.ctx.Err(); err != nil {
+			return nil, err
+		}
+		entry := PlanEntry{Source: item.Source}
+		entry.Destination, err = renderAndJoin(r.exec, item.Nodes)
+		if err != nil {
+			return nil, err
+		}
+
+		var included bool
+		if included, err = r.included(item.Source, item.IsDir); err != nil {
+			return nil, err
+		}
+
+		switch {
+		case entry.Destination == "" || !included:
+			entry.Action = ActionSkip
+		case item.Symlink && r.scan.Symlinks == fs.SymlinkSkip:
+			entry.Action = ActionSkip
+		case item.Symlink:
+			entry.Action = ActionSymlink
+		case item.IsDir:
+			entry.Action = ActionDirectory
+		case isVerbatim(item.Source, r.verbs):
+			entry.Action = ActionVerbatim
+		case !isTextFile(r.fsys, item.Source):
+			entry.Action = ActionBinary
+		default:
+			entry.Action = ActionTemplate
+		}
+		result = append(result, entry)
+	}
+	return result, nil
+}
+
+// PlanFS computes how a template contained in a given file system would be
+// rendered using props as variables and an optional Options structure,
+// without writing anything. File contents are not rendered, so errors
+// within them are only detected when actually rendering the template.
+func PlanFS(props props.Lookup, fsys iofs.FS, opts *Options) (Plan, error) {
+	r, err := newRenderer(context.Background(), props, fsys, opts)
+	if err != nil {
+		return nil, err
+	}
+	return r.plan()
+}
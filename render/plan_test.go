@@ -0,0 +1,41 @@
+package render
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gympass/go-giter8/props"
+)
+
+func TestPlanFS(t *testing.T) {
+	p := props.Pairs{{K: "name", V: "Foo"}, {K: "verbatim", V: "*.html"}, {K: "docker", V: "no"}}
+	plan, err := PlanFS(p, templateFS(), nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, Plan{
+		{Source: "$if(docker.truthy)$Dockerfile$endif$", Action: ActionSkip},
+		{Source: "$name$", Destination: "Foo", Action: ActionDirectory},
+		{Source: "$name$/README.md", Destination: "Foo/README.md", Action: ActionTemplate},
+		{Source: "$name$/docs", Destination: "Foo/docs", Action: ActionDirectory},
+		{Source: "$name$/docs/index.html", Destination: "Foo/docs/index.html", Action: ActionVerbatim},
+		{Source: "$name$/run.sh", Destination: "Foo/run.sh", Action: ActionTemplate},
+		{Source: "$name$/static", Destination: "Foo/static", Action: ActionDirectory},
+		{Source: "$name$/static/logo.bin", Destination: "Foo/static/logo.bin", Action: ActionBinary},
+	}, plan)
+}
+
+func TestPlanJSON(t *testing.T) {
+	plan := Plan{
+		{Source: "$name$/README.md", Destination: "Foo/README.md", Action: ActionTemplate},
+		{Source: "$if(a.truthy)$a$endif$", Action: ActionSkip},
+	}
+	data, err := json.Marshal(plan)
+	require.NoError(t, err)
+	assert.JSONEq(t, `[
+		{"source": "$name$/README.md", "destination": "Foo/README.md", "action": "template"},
+		{"source": "$if(a.truthy)$a$endif$", "action": "skip"}
+	]`, string(data))
+}
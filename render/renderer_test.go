@@ -1,6 +1,8 @@
 package render
 
 import (
+	"context"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -22,3 +24,33 @@ func TestRenderer(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, "foo", res)
 }
+
+func TestRendererWithMap(t *testing.T) {
+	ast, err := lexer.Tokenize(`$name$ $if(enabled.truthy)$on$endif$`)
+	require.NoError(t, err)
+	m := props.NewMap(props.Pairs{{K: "name", V: "foo"}, {K: "enabled", V: "no"}})
+	exec := NewExecutor(m)
+	res, err := exec.Exec(ast)
+	require.NoError(t, err)
+	assert.Equal(t, "foo ", res)
+
+	m.Set("enabled", "yes")
+	res, err = exec.Exec(ast)
+	require.NoError(t, err)
+	assert.Equal(t, "foo on", res)
+}
+
+func TestRendererContext(t *testing.T) {
+	ast, err := lexer.Tokenize(strings.Repeat("$name$ ", 1000))
+	require.NoError(t, err)
+	e := NewExecutor(props.Pairs{{K: "name", V: "foo"}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	res, err := e.ExecContext(ctx, ast)
+	require.NoError(t, err)
+	assert.Equal(t, strings.Repeat("foo ", 1000), res)
+
+	cancel()
+	_, err = e.ExecContext(ctx, ast)
+	assert.Equal(t, context.Canceled, err)
+}
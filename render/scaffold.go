@@ -0,0 +1,69 @@
+package render
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/gympass/go-giter8/props"
+)
+
+// ScaffoldsDir is the directory, within a generated project, holding
+// scaffolds shipped by its template. Each scaffold is a template kept in a
+// directory named after it, which can be applied to the project later.
+const ScaffoldsDir = ".g8"
+
+// Scaffolds returns the names of all scaffolds available within a given
+// project directory, sorted. Returns no names in case the project has no
+// scaffolds.
+func Scaffolds(projectRoot string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(projectRoot, ScaffoldsDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// ScaffoldPath returns the path of a scaffold with a given name within a
+// project directory. Returns an error in case the scaffold does not exist.
+func ScaffoldPath(projectRoot, name string) (string, error) {
+	if name == "" || name != filepath.Base(name) || name == "." || name == ".." {
+		return "", fmt.Errorf("invalid scaffold name `%s'", name)
+	}
+	p := filepath.Join(projectRoot, ScaffoldsDir, name)
+	stat, err := os.Stat(p)
+	if os.IsNotExist(err) || (err == nil && !stat.IsDir()) {
+		return "", fmt.Errorf("scaffold `%s' does not exist", name)
+	} else if err != nil {
+		return "", err
+	}
+	return p, nil
+}
+
+// ScaffoldDirectory renders a scaffold with a given name into the project
+// it belongs to, using props as variables and an optional Options structure.
+// Files generated by the scaffold are handled as described by TemplateFS, so
+// conflicts with existing project files follow Options.OnConflict.
+func ScaffoldDirectory(props props.Lookup, projectRoot, name string, opts *Options) error {
+	source, err := ScaffoldPath(projectRoot, name)
+	if err != nil {
+		return err
+	}
+	// The project always exists
+	scaffoldOpts := Options{}
+	if opts != nil {
+		scaffoldOpts = *opts
+	}
+	scaffoldOpts.AllowExisting = true
+	return TemplateDirectoryOpts(props, source, projectRoot, &scaffoldOpts)
+}
@@ -0,0 +1,70 @@
+package render
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	gfs "github.com/gympass/go-giter8/fs"
+	"github.com/gympass/go-giter8/props"
+)
+
+func scaffoldProject(t *testing.T) string {
+	project := t.TempDir()
+	out := gfs.NewDirOutput(project)
+	scaffolds := fstest.MapFS{
+		"controller/default.properties":        {Data: []byte("name=Index\n")},
+		"controller/src/$name$Controller.go":   {Data: []byte("type $name$Controller struct{}\n"), Mode: 0644},
+		"model/src/$name;format=\"lower\"$.go": {Data: []byte("type $name$ struct{}\n"), Mode: 0644},
+	}
+	require.NoError(t, gfs.CopyFS(out, ScaffoldsDir, scaffolds))
+	require.NoError(t, gfs.WriteFile(out, "README.md", []byte("Project\n"), 0644))
+	return project
+}
+
+func TestScaffolds(t *testing.T) {
+	names, err := Scaffolds(scaffoldProject(t))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"controller", "model"}, names)
+
+	names, err = Scaffolds(t.TempDir())
+	require.NoError(t, err)
+	assert.Empty(t, names)
+}
+
+func TestScaffoldPath(t *testing.T) {
+	project := scaffoldProject(t)
+	p, err := ScaffoldPath(project, "model")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(project, ScaffoldsDir, "model"), p)
+
+	_, err = ScaffoldPath(project, "view")
+	assert.EqualError(t, err, "scaffold `view' does not exist")
+	_, err = ScaffoldPath(project, "../model")
+	assert.EqualError(t, err, "invalid scaffold name `../model'")
+}
+
+func TestScaffoldDirectory(t *testing.T) {
+	project := scaffoldProject(t)
+	p := props.Pairs{{K: "name", V: "User"}}
+	require.NoError(t, ScaffoldDirectory(p, project, "controller", nil))
+
+	contents, err := os.ReadFile(filepath.Join(project, "src", "UserController.go"))
+	require.NoError(t, err)
+	assert.Equal(t, "type UserController struct{}\n", string(contents))
+	contents, err = os.ReadFile(filepath.Join(project, "README.md"))
+	require.NoError(t, err)
+	assert.Equal(t, "Project\n", string(contents))
+	_, err = os.Stat(filepath.Join(project, "default.properties"))
+	assert.True(t, os.IsNotExist(err))
+
+	err = ScaffoldDirectory(p, project, "controller", nil)
+	assert.Error(t, err)
+	require.NoError(t, ScaffoldDirectory(p, project, "controller", &Options{OnConflict: ConflictOverwrite}))
+
+	assert.Error(t, ScaffoldDirectory(p, project, "view", nil))
+}
@@ -0,0 +1,190 @@
+package render
+
+import (
+	iofs "io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	gfs "github.com/gympass/go-giter8/fs"
+	"github.com/gympass/go-giter8/props"
+)
+
+func symlinkTemplate(t *testing.T) string {
+	source := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(source, "shared"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(source, "shared", "config.yml"), []byte("name: $name$\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(source, "README.md"), []byte("# $name$\n"), 0644))
+	if err := os.Symlink("README.md", filepath.Join(source, "INDEX.md")); err != nil {
+		t.Skipf("symbolic links are not supported: %s", err)
+	}
+	require.NoError(t, os.Symlink("shared", filepath.Join(source, "config")))
+	require.NoError(t, os.Symlink("$name$.yml", filepath.Join(source, "current.yml")))
+	return source
+}
+
+func TestSymlinkFollow(t *testing.T) {
+	source := symlinkTemplate(t)
+	destination := filepath.Join(t.TempDir(), "out")
+	p := props.Pairs{{K: "name", V: "Foo"}}
+	require.NoError(t, os.Remove(filepath.Join(source, "current.yml")))
+	// Relative targets are resolved from the directory the link lives in,
+	// even when reached through another link.
+	require.NoError(t, os.Symlink("../README.md", filepath.Join(source, "shared", "README.md")))
+	require.NoError(t, TemplateDirectory(p, source, destination))
+
+	contents, err := os.ReadFile(filepath.Join(destination, "INDEX.md"))
+	require.NoError(t, err)
+	assert.Equal(t, "# Foo\n", string(contents))
+	contents, err = os.ReadFile(filepath.Join(destination, "config", "config.yml"))
+	require.NoError(t, err)
+	assert.Equal(t, "name: Foo\n", string(contents))
+	contents, err = os.ReadFile(filepath.Join(destination, "config", "README.md"))
+	require.NoError(t, err)
+	assert.Equal(t, "# Foo\n", string(contents))
+
+	stat, err := os.Lstat(filepath.Join(destination, "config"))
+	require.NoError(t, err)
+	assert.True(t, stat.IsDir())
+}
+
+func TestSymlinkFollowBroken(t *testing.T) {
+	source := symlinkTemplate(t)
+	p := props.Pairs{{K: "name", V: "Foo"}}
+	err := TemplateDirectory(p, source, filepath.Join(t.TempDir(), "out"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "error following symlink current.yml")
+
+	// Links cannot be checked in file systems unable to describe them
+	plain := struct{ iofs.FS }{os.DirFS(source)}
+	err = TemplateOutput(p, plain, gfs.NewMemOutput(), nil)
+	assert.EqualError(t, err, "cannot follow symlink INDEX.md: file system does not support symbolic links")
+}
+
+func TestSymlinkLoop(t *testing.T) {
+	source := symlinkTemplate(t)
+	require.NoError(t, os.Remove(filepath.Join(source, "current.yml")))
+	require.NoError(t, os.Symlink("..", filepath.Join(source, "shared", "parent")))
+
+	p := props.Pairs{{K: "name", V: "Foo"}}
+	err := TemplateDirectory(p, source, filepath.Join(t.TempDir(), "out"))
+	assert.EqualError(t, err, "symlink loop detected at config/parent")
+
+	opts := &Options{Symlinks: gfs.SymlinkPreserve}
+	require.NoError(t, TemplateDirectoryOpts(p, source, filepath.Join(t.TempDir(), "out"), opts))
+}
+
+func TestSymlinkFollowOutside(t *testing.T) {
+	outside := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(outside, "id_rsa"), []byte("secret\n"), 0600))
+	p := props.Pairs{{K: "name", V: "Foo"}}
+
+	for link, target := range map[string]string{
+		"home":             outside,
+		"parent":           "..",
+		"shared/escape":    "../../" + filepath.Base(outside),
+		"config/escape":    "../..",
+		"shared/key.txt":   filepath.Join(outside, "id_rsa"),
+		"shared/nested/up": "../../..",
+	} {
+		t.Run(link, func(t *testing.T) {
+			source := symlinkTemplate(t)
+			require.NoError(t, os.Remove(filepath.Join(source, "current.yml")))
+			name := filepath.Join(source, filepath.FromSlash(link))
+			require.NoError(t, os.MkdirAll(filepath.Dir(name), 0755))
+			require.NoError(t, os.Symlink(target, name))
+
+			destination := filepath.Join(t.TempDir(), "out")
+			err := TemplateDirectory(p, source, destination)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "points outside of the template")
+			_, err = os.Stat(destination)
+			assert.True(t, os.IsNotExist(err))
+
+			// Preserved links are never followed
+			opts := &Options{Symlinks: gfs.SymlinkPreserve}
+			assert.NoError(t, TemplateDirectoryOpts(p, source, filepath.Join(t.TempDir(), "out"), opts))
+		})
+	}
+}
+
+func TestSymlinkFollowExcluded(t *testing.T) {
+	source := symlinkTemplate(t)
+	require.NoError(t, os.Symlink(t.TempDir(), filepath.Join(source, "dev-link")))
+	require.NoError(t, os.WriteFile(filepath.Join(source, gfs.IgnoreFile), []byte("dev-link\ncurrent.yml\n"), 0644))
+
+	// Excluded links are neither followed nor checked, whether they point
+	// outside of the template or are broken.
+	destination := filepath.Join(t.TempDir(), "out")
+	p := props.Pairs{{K: "name", V: "Foo"}}
+	require.NoError(t, TemplateDirectory(p, source, destination))
+	_, err := os.Lstat(filepath.Join(destination, "dev-link"))
+	assert.True(t, os.IsNotExist(err))
+
+	require.NoError(t, os.Remove(filepath.Join(source, gfs.IgnoreFile)))
+	p = props.Pairs{{K: "name", V: "Foo"}, {K: "exclude", V: "dev-link current.yml"}}
+	require.NoError(t, TemplateDirectory(p, source, filepath.Join(t.TempDir(), "out")))
+}
+
+func TestSymlinkPreserve(t *testing.T) {
+	source := symlinkTemplate(t)
+	destination := filepath.Join(t.TempDir(), "out")
+	p := props.Pairs{{K: "name", V: "Foo"}}
+	var events []EventKind
+	opts := &Options{
+		Symlinks: gfs.SymlinkPreserve,
+		OnEvent: func(e Event) {
+			if e.Source == "current.yml" {
+				events = append(events, e.Kind)
+			}
+		},
+	}
+	require.NoError(t, TemplateDirectoryOpts(p, source, destination, opts))
+	assert.Equal(t, []EventKind{EventWriting, EventSymlinked}, events)
+
+	for link, target := range map[string]string{"INDEX.md": "README.md", "config": "shared", "current.yml": "Foo.yml"} {
+		actual, err := os.Readlink(filepath.Join(destination, link))
+		require.NoError(t, err, link)
+		assert.Equal(t, target, filepath.ToSlash(actual), link)
+	}
+
+	plan, err := PlanFS(p, gfs.NewDirFS(source), opts)
+	require.NoError(t, err)
+	assert.Contains(t, plan, PlanEntry{Source: "config", Destination: "config", Action: ActionSymlink})
+	assert.NotContains(t, plan, PlanEntry{Source: "config/config.yml", Destination: "config/config.yml", Action: ActionTemplate})
+
+	out := gfs.NewMemOutput()
+	require.NoError(t, TemplateOutput(p, gfs.NewDirFS(source), out, opts))
+	stat, err := out.Stat("current.yml")
+	require.NoError(t, err)
+	assert.NotZero(t, stat.Mode()&os.ModeSymlink)
+	contents, err := out.ReadFile("current.yml")
+	require.NoError(t, err)
+	assert.Equal(t, "Foo.yml", string(contents))
+
+	// File systems unable to describe symbolic links cannot preserve them
+	plain := struct{ iofs.FS }{os.DirFS(source)}
+	err = TemplateOutput(p, plain, gfs.NewMemOutput(), opts)
+	assert.EqualError(t, err, "readlink INDEX.md: file system does not support symbolic links")
+}
+
+func TestSymlinkSkip(t *testing.T) {
+	source := symlinkTemplate(t)
+	out := gfs.NewMemOutput()
+	p := props.Pairs{{K: "name", V: "Foo"}}
+	require.NoError(t, TemplateOutput(p, gfs.NewDirFS(source), out, &Options{Symlinks: gfs.SymlinkSkip}))
+	assert.Equal(t, []string{"README.md", "shared", "shared/config.yml"}, out.Names())
+}
+
+func TestParseSymlinkPolicy(t *testing.T) {
+	for _, p := range []gfs.SymlinkPolicy{gfs.SymlinkFollow, gfs.SymlinkPreserve, gfs.SymlinkSkip} {
+		parsed, err := gfs.ParseSymlinkPolicy(p.String())
+		require.NoError(t, err)
+		assert.Equal(t, p, parsed)
+	}
+	_, err := gfs.ParseSymlinkPolicy("copy")
+	assert.EqualError(t, err, "unknown symlink policy `copy'")
+}
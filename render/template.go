@@ -1,12 +1,12 @@
 package render
 
 import (
+	"context"
 	"fmt"
 	"io"
+	iofs "io/fs"
 	"os"
-	"path/filepath"
-	"regexp"
-	"strings"
+	"path"
 	"unicode/utf8"
 
 	"github.com/gympass/go-giter8/fs"
@@ -24,6 +24,65 @@ type AfterRenderCallback func(info os.FileInfo, contents string) (string, error)
 
 type Options struct {
 	AfterRenderCallback AfterRenderCallback
+
+	// AllowExisting lets TemplateFS and TemplateDirectory render into a
+	// destination that already exists, which they refuse otherwise. Files
+	// conflicting with existing ones are then handled according to
+	// OnConflict.
+	AllowExisting bool
+	// OnConflict determines how to handle generated files conflicting with
+	// existing ones. Defaults to ConflictFail.
+	OnConflict ConflictPolicy
+	// ConflictHandler decides how to handle each conflicting file when
+	// OnConflict is ConflictAsk.
+	ConflictHandler ConflictHandler
+
+	// Workers determines how many files are rendered concurrently. Values
+	// lower than 2 render files one at a time. Regardless of this setting,
+	// files are written in the same order, and ConflictHandler, OnEvent and
+	// AfterCopy are never called concurrently. AfterRenderCallback,
+	// BeforeRender and AfterRender, however, must be safe for concurrent use
+	// when Workers is greater than 1.
+	Workers int
+
+	// Delimiters sets the delimiters used by template files matching given
+	// patterns. Rules set through the `delimiters' property are applied after
+	// these, and the last matching rule wins. A `g8:delimiters' directive
+	// within a file, described by lexer.ExtractDelimiters, takes precedence
+	// over all rules.
+	Delimiters []DelimiterRule
+
+	// Conditions includes files and directories matching given patterns only
+	// when their conditions hold. Rules listed by the template's
+	// ConditionsFile are applied as well, and items are only included when
+	// all rules matching them hold.
+	Conditions []ConditionRule
+
+	// Symlinks determines how symbolic links within the template are
+	// handled. Defaults to fs.SymlinkFollow. Preserving symbolic links
+	// requires the template's file system to implement fs.ReadLinkFS, as the
+	// ones used by TemplateDirectory do, and the output to implement
+	// fs.SymlinkOutput.
+	Symlinks fs.SymlinkPolicy
+
+	// Formatters post-processes rendered files based on their destination
+	// name, after AfterRenderCallback is called. Files not matching any
+	// formatter are kept as-is. See DefaultFormatters for built-in ones.
+	Formatters Formatters
+
+	// OnEvent receives progress events for every item of the template
+	OnEvent EventHandler
+
+	// BeforeRender is called before each file is rendered or copied, and may
+	// skip it or change its destination.
+	BeforeRender FileCallback
+	// AfterRender is called after a file is rendered as a template, and may
+	// replace its contents, skip it, or change its destination. It is called
+	// after AfterRenderCallback and Formatters.
+	AfterRender FileCallback
+	// AfterCopy is called after a file is copied as-is into the output.
+	// Changing the FileContext has no effect.
+	AfterCopy FileCallback
 }
 
 func isText(s []byte) bool {
@@ -44,8 +103,8 @@ func isText(s []byte) bool {
 	return true
 }
 
-func isTextFile(path string) bool {
-	f, err := os.Open(path)
+func isTextFile(fsys iofs.FS, path string) bool {
+	f, err := fsys.Open(path)
 	if err != nil {
 		return false
 	}
@@ -60,8 +119,8 @@ func isTextFile(path string) bool {
 	return isText(buf[0:n])
 }
 
-func copyFile(src, dst string) error {
-	sourceStat, err := os.Stat(src)
+func copyFile(fsys iofs.FS, src string, out fs.Output, dst string) error {
+	sourceStat, err := iofs.Stat(fsys, src)
 	if err != nil {
 		return err
 	}
@@ -70,42 +129,23 @@ func copyFile(src, dst string) error {
 		return fmt.Errorf("%s is not a regular file", src)
 	}
 
-	source, err := os.Open(src)
+	source, err := fsys.Open(src)
 	if err != nil {
 		return err
 	}
 	defer source.Close()
 
-	if _, err = os.Stat(dst); err == nil {
-		return fmt.Errorf("%s: destination file already exists", dst)
-	}
-
-	destination, err := os.Create(dst)
+	destination, err := out.Create(dst, sourceStat.Mode())
 	if err != nil {
 		return err
 	}
-	defer destination.Close()
 
-	if err = destination.Chmod(sourceStat.Mode()); err != nil {
+	if _, err = io.Copy(destination, source); err != nil {
+		_ = destination.Close()
 		return err
 	}
 
-	buf := make([]byte, 4096)
-	for {
-		n, err := source.Read(buf)
-		if err != nil && err != io.EOF {
-			return err
-		}
-		if n == 0 {
-			break
-		}
-
-		if _, err = destination.Write(buf[:n]); err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return destination.Close()
 }
 
 func renderAndJoin(exec *Executor, nodes []fs.Node) (string, error) {
@@ -122,114 +162,365 @@ func renderAndJoin(exec *Executor, nodes []fs.Node) (string, error) {
 			items = append(items, r)
 		}
 	}
-	return filepath.Join(items...), nil
+	return path.Join(items...), nil
 }
 
-func isVerbatim(source string, patterns []*regexp.Regexp) bool {
-	for _, p := range patterns {
-		if p.MatchString(source) {
-			return true
-		}
-	}
-	return false
+// isVerbatim indicates whether a file must be copied as-is. source is the
+// path of the file relative to the template root, so that anchored patterns
+// apply to the template and never to the directory containing it.
+func isVerbatim(source string, patterns fs.GlobSet) bool {
+	return patterns.Match(source, false)
 }
 
 // TemplateDirectory renders a given source template using props as variables
 // into a given destination. Destination must not exist.
 // Calling this function is the same as calling TemplateDirectoryOpts without
 // options.
-func TemplateDirectory(props props.Pairs, source, destination string) error {
+func TemplateDirectory(props props.Lookup, source, destination string) error {
 	return TemplateDirectoryOpts(props, source, destination, nil)
 }
 
 // TemplateDirectoryOpts renders a given source template into a given
 // destination using props as variables and an optional Options structure.
-// Destination must not exist.
-func TemplateDirectoryOpts(props props.Pairs, source, destination string, opts *Options) error {
-	items, err := fs.ScanTree(source)
+// props may be either a props.Pairs slice or a *props.Map.
+// Destination is handled, and left untouched in case rendering fails, as
+// described by TemplateFS.
+func TemplateDirectoryOpts(props props.Lookup, source, destination string, opts *Options) error {
+	return TemplateDirectoryContext(context.Background(), props, source, destination, opts)
+}
+
+// TemplateDirectoryContext works like TemplateDirectoryOpts, but stops
+// rendering once ctx is done, returning ctx.Err() and leaving destination
+// untouched.
+func TemplateDirectoryContext(ctx context.Context, props props.Lookup, source, destination string, opts *Options) error {
+	return TemplateFSContext(ctx, props, fs.NewDirFS(source), destination, opts)
+}
+
+// TemplateFS renders a template contained in a given file system into a given
+// destination using props as variables and an optional Options structure.
+// This allows templates to be read from sources other than the operating
+// system's file system, such as embed.FS, zip archives, or fstest.MapFS.
+// Destination must not exist, unless Options.AllowExisting is set, in which
+// case generated files conflicting with existing ones are handled according
+// to Options.OnConflict.
+// Files are rendered into a staging directory through fs.StagedOutput, and
+// only moved into destination once the whole template is rendered. In case
+// rendering fails, destination is left untouched; see fs.StagedOutput.Commit
+// for how files are moved into an existing destination.
+func TemplateFS(props props.Lookup, fsys iofs.FS, destination string, opts *Options) error {
+	return TemplateFSContext(context.Background(), props, fsys, destination, opts)
+}
+
+// TemplateFSContext works like TemplateFS, but stops rendering once ctx is
+// done, returning ctx.Err() and leaving destination untouched.
+func TemplateFSContext(ctx context.Context, props props.Lookup, fsys iofs.FS, destination string, opts *Options) error {
+	if opts == nil || !opts.AllowExisting {
+		if _, err := os.Stat(destination); err == nil {
+			return fmt.Errorf("destination %s already exists", destination)
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+	}
+	out, err := fs.NewStagedOutput(destination)
 	if err != nil {
 		return err
 	}
-
-	// Stat destination...
-	_, err = os.Stat(destination)
-	if err == nil {
-		return fmt.Errorf("destination %s already exists", destination)
-	} else if !os.IsNotExist(err) {
+	if err = TemplateOutputContext(ctx, props, fsys, out, opts); err != nil {
+		_ = out.Rollback()
 		return err
 	}
+	return out.Commit()
+}
+
+// TemplateOutput renders a template contained in a given file system into a
+// given fs.Output using props as variables and an optional Options
+// structure. This allows rendered templates to be kept in memory through
+// fs.MemOutput, or written into other kinds of storage.
+func TemplateOutput(props props.Lookup, fsys iofs.FS, out fs.Output, opts *Options) error {
+	return TemplateOutputContext(context.Background(), props, fsys, out, opts)
+}
 
-	if err = os.MkdirAll(destination, os.ModePerm); err != nil {
+// TemplateOutputContext works like TemplateOutput, but stops rendering once
+// ctx is done, returning ctx.Err(). Files already written to out are kept;
+// use fs.StagedOutput or fs.MemOutput in case they must be discarded.
+func TemplateOutputContext(ctx context.Context, props props.Lookup, fsys iofs.FS, out fs.Output, opts *Options) error {
+	r, err := newRenderer(ctx, props, fsys, opts)
+	if err != nil {
+		return err
+	}
+	plan, err := r.plan()
+	if err != nil {
 		return err
 	}
+	return r.execute(plan, out)
+}
 
-	exec := NewExecutor(props)
-	verb, verbOK := props.Fetch("verbatim")
-	var verbs []*regexp.Regexp
-	for _, v := range strings.Split(verb, " ") {
-		v = strings.TrimSpace(v)
-		if len(v) > 0 {
-			reg := fs.CreateSGlob(v)
-			verbs = append(verbs, reg)
-		}
+// rendered holds the result of preparing a file to be written to an output
+type rendered struct {
+	file *FileContext
+	err  error
+}
+
+// render prepares a file to be written, calling BeforeRender and, for files
+// handled by ActionTemplate, rendering it. Directories and skipped items
+// require no preparation.
+func (r *renderer) render(entry PlanEntry) (res rendered) {
+	if entry.Action == ActionSkip || entry.Action == ActionDirectory {
+		return
 	}
 
-	var path string
+	var fileStat os.FileInfo
+	var err error
+	if entry.Action == ActionSymlink {
+		fileStat, err = fs.Lstat(r.fsys, entry.Source)
+	} else {
+		fileStat, err = iofs.Stat(r.fsys, entry.Source)
+	}
+	if err != nil {
+		res.err = err
+		return
+	}
+	f := &FileContext{
+		Source:      entry.Source,
+		Destination: entry.Destination,
+		Info:        fileStat,
+		Props:       readOnlyProps{r.exec.props},
+		Action:      entry.Action,
+	}
+	res.file = f
 
-	for _, item := range items {
-		path, err = renderAndJoin(exec, item.Nodes)
-		if err != nil {
-			return err
+	if r.opts != nil && r.opts.BeforeRender != nil {
+		if res.err = r.opts.BeforeRender(f); res.err != nil || f.Skip {
+			return
 		}
-		if path == "" {
-			continue
+	}
+	if entry.Action == ActionSymlink {
+		if f.Contents, res.err = r.renderSymlink(entry.Source); res.err == nil && r.opts != nil && r.opts.AfterRender != nil {
+			res.err = r.opts.AfterRender(f)
 		}
-		path = filepath.Join(destination, path)
+		return
+	}
+	if entry.Action != ActionTemplate {
+		return
+	}
 
-		if item.IsDir {
-			if err = os.MkdirAll(path, os.ModePerm); err != nil {
-				return err
-			}
-			continue
-		}
+	fileContents, err := iofs.ReadFile(r.fsys, entry.Source)
+	if err != nil {
+		res.err = err
+		return
+	}
+	ast, err := r.tokenize(entry.Source, string(fileContents))
+	if err != nil {
+		res.err = fmt.Errorf("error parsing %s: %s", entry.Source, err)
+		return
+	}
 
-		if (verbOK && isVerbatim(item.Source, verbs)) || !isTextFile(item.Source) {
-			// Just... copy it?
-			if err = copyFile(item.Source, path); err != nil {
-				return err
-			}
-			continue
-		}
-		fileStat, err := os.Stat(item.Source)
-		if err != nil {
-			return err
+	f.Contents, err = r.exec.ExecContext(r.ctx, ast)
+	if err != nil && err == r.ctx.Err() {
+		res.err = err
+		return
+	} else if err != nil {
+		res.err = fmt.Errorf("error rendering %s: %s", entry.Source, err)
+		return
+	}
+
+	if r.opts != nil && r.opts.AfterRenderCallback != nil {
+		if f.Contents, res.err = (r.opts.AfterRenderCallback)(fileStat, f.Contents); res.err != nil {
+			return
 		}
-		fileContents, err := os.ReadFile(item.Source)
-		if err != nil {
-			return err
+	}
+	if r.opts != nil {
+		if format, ok := r.opts.Formatters.find(f.Destination); ok {
+			if f.Contents, err = format(f.Contents); err != nil {
+				res.err = fmt.Errorf("error formatting %s: %s", f.Destination, err)
+				return
+			}
 		}
-		ast, err := lexer.Tokenize(string(fileContents))
-		if err != nil {
-			return fmt.Errorf("error parsing %s: %s", item.Source, err)
+	}
+	if r.opts != nil && r.opts.AfterRender != nil {
+		res.err = r.opts.AfterRender(f)
+	}
+	return
+}
+
+// renderSymlink returns the target of a symbolic link, rendered as a
+// template
+func (r *renderer) renderSymlink(source string) (string, error) {
+	target, err := fs.ReadLink(r.fsys, source)
+	if err != nil {
+		return "", err
+	}
+	ast, err := lexer.Tokenize(target)
+	if err != nil {
+		return "", fmt.Errorf("error parsing target of %s: %s", source, err)
+	}
+	target, err = r.exec.ExecContext(r.ctx, ast)
+	if err != nil && err == r.ctx.Err() {
+		return "", err
+	} else if err != nil {
+		return "", fmt.Errorf("error rendering target of %s: %s", source, err)
+	}
+	return target, nil
+}
+
+// emit reports an event to Options.OnEvent, if any
+func (r *renderer) emit(e Event) {
+	if r.opts != nil && r.opts.OnEvent != nil {
+		r.opts.OnEvent(e)
+	}
+}
+
+// write writes an entry previously prepared by render into out, returning
+// the kind of event describing the outcome.
+func (r *renderer) write(entry PlanEntry, res rendered, out fs.Output) (EventKind, string, error) {
+	switch entry.Action {
+	case ActionSkip:
+		return EventSkipped, "", nil
+	case ActionDirectory:
+		return EventDirectoryCreated, entry.Destination, out.MkdirAll(entry.Destination, 0755)
+	}
+
+	if res.err != nil {
+		return EventError, entry.Destination, res.err
+	}
+	f := res.file
+	if f.Skip {
+		return EventSkipped, f.Destination, nil
+	}
+
+	dest, err := resolveConflict(out, f.Destination, r.opts)
+	if err != nil {
+		return EventError, f.Destination, err
+	} else if dest == "" {
+		return EventSkipped, f.Destination, nil
+	}
+
+	switch entry.Action {
+	case ActionTemplate:
+		return EventRendered, dest, fs.WriteFile(out, dest, []byte(f.Contents), f.Info.Mode())
+	case ActionSymlink:
+		return EventSymlinked, dest, fs.Symlink(out, f.Contents, dest)
+	}
+
+	// Just... copy it?
+	if err = copyFile(r.fsys, entry.Source, out, dest); err != nil {
+		return EventError, dest, err
+	}
+	if r.opts != nil && r.opts.AfterCopy != nil {
+		f.Destination = dest
+		if err = r.opts.AfterCopy(f); err != nil {
+			return EventError, dest, err
 		}
+	}
+	return EventCopied, dest, nil
+}
 
-		contents, err := exec.Exec(ast)
-		if err != nil {
-			return fmt.Errorf("error rendering %s: %s", item.Source, err)
+// writeEntry writes an entry into out, reporting its progress through
+// Options.OnEvent
+func (r *renderer) writeEntry(plan Plan, i int, res rendered, out fs.Output) error {
+	entry := plan[i]
+	if entry.Action != ActionSkip && entry.Action != ActionDirectory {
+		dest := entry.Destination
+		if res.file != nil {
+			dest = res.file.Destination
 		}
+		r.emit(Event{Kind: EventWriting, Source: entry.Source, Destination: dest, Done: i, Total: len(plan)})
+	}
+	kind, dest, err := r.write(entry, res, out)
+	if err != nil {
+		r.emit(Event{Kind: EventError, Source: entry.Source, Destination: dest, Err: err, Done: i, Total: len(plan)})
+		return err
+	}
+	r.emit(Event{Kind: kind, Source: entry.Source, Destination: dest, Done: i + 1, Total: len(plan)})
+	return nil
+}
 
-		if opts != nil && opts.AfterRenderCallback != nil {
-			contents, err = (opts.AfterRenderCallback)(fileStat, contents)
-			if err != nil {
+func (r *renderer) execute(plan Plan, out fs.Output) error {
+	workers := 1
+	if r.opts != nil && r.opts.Workers > 1 {
+		workers = r.opts.Workers
+	}
+	if workers == 1 {
+		for i, entry := range plan {
+			if err := r.ctx.Err(); err != nil {
+				return err
+			}
+			if err := r.writeEntry(plan, i, r.render(entry), out); err != nil {
 				return err
 			}
 		}
+		return nil
+	}
 
-		err = os.WriteFile(path, []byte(contents), fileStat.Mode())
-		if err != nil {
-			return err
+	// Files are rendered by a pool of workers, while results are written in
+	// the same order as the plan. This ensures directories are created before
+	// their contents, and that the first error in the plan is the one
+	// returned. window bounds how many rendered files may be kept in memory
+	// waiting to be written.
+	results := make([]chan rendered, len(plan))
+	for i := range results {
+		results[i] = make(chan rendered, 1)
+	}
+	window := make(chan struct{}, workers*2)
+	jobs := make(chan int)
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		defer close(jobs)
+		for i := range plan {
+			select {
+			case window <- struct{}{}:
+			case <-done:
+				return
+			}
+			select {
+			case jobs <- i:
+			case <-done:
+				return
+			}
 		}
+	}()
+	for w := 0; w < workers; w++ {
+		go func() {
+			for i := range jobs {
+				results[i] <- r.render(plan[i])
+			}
+		}()
 	}
 
+	for i := range plan {
+		var res rendered
+		select {
+		case res = <-results[i]:
+		case <-r.ctx.Done():
+			return r.ctx.Err()
+		}
+		<-window
+		if err := r.ctx.Err(); err != nil {
+			return err
+		}
+		if err := r.writeEntry(plan, i, res, out); err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+// TemplateArchive renders a template contained in a given file system into
+// an archive with a given format, written to w. File modes are preserved
+// within the archive. w is not closed.
+func TemplateArchive(props props.Lookup, fsys iofs.FS, w io.Writer, format fs.ArchiveFormat, opts *Options) error {
+	return TemplateArchiveContext(context.Background(), props, fsys, w, format, opts)
+}
+
+// TemplateArchiveContext works like TemplateArchive, but stops rendering once
+// ctx is done, returning ctx.Err(). In that case, the archive written to w is
+// incomplete.
+func TemplateArchiveContext(ctx context.Context, props props.Lookup, fsys iofs.FS, w io.Writer, format fs.ArchiveFormat, opts *Options) error {
+	out := fs.NewArchiveOutput(w, format)
+	if err := TemplateOutputContext(ctx, props, fsys, out, opts); err != nil {
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,414 @@
+package render
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	gfs "github.com/gympass/go-giter8/fs"
+	"github.com/gympass/go-giter8/lexer"
+	"github.com/gympass/go-giter8/props"
+)
+
+func templateFS() fstest.MapFS {
+	return fstest.MapFS{
+		"default.properties":                   {Data: []byte("name=Project\n")},
+		"$name$/README.md":                     {Data: []byte("Hello, $name$!\n"), Mode: 0644},
+		"$name$/run.sh":                        {Data: []byte("echo $name;format=\"lower\"$\n"), Mode: 0755},
+		"$name$/docs/index.html":               {Data: []byte("<p>$verbatim$</p>\n"), Mode: 0644},
+		"$name$/static/logo.bin":               {Data: []byte{0x89, 'P', 'N', 'G', 0x00, 0x01, 0x02}, Mode: 0644},
+		"$if(docker.truthy)$Dockerfile$endif$": {Data: []byte("FROM scratch\n"), Mode: 0644},
+	}
+}
+
+func TestTemplateFS(t *testing.T) {
+	destination := filepath.Join(t.TempDir(), "out")
+	p := props.Pairs{{K: "name", V: "Foo"}, {K: "verbatim", V: "*.html"}, {K: "docker", V: "no"}}
+	require.NoError(t, TemplateFS(p, templateFS(), destination, nil))
+
+	contents, err := os.ReadFile(filepath.Join(destination, "Foo", "README.md"))
+	require.NoError(t, err)
+	assert.Equal(t, "Hello, Foo!\n", string(contents))
+
+	stat, err := os.Stat(filepath.Join(destination, "Foo", "run.sh"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), stat.Mode().Perm())
+
+	contents, err = os.ReadFile(filepath.Join(destination, "Foo", "docs", "index.html"))
+	require.NoError(t, err)
+	assert.Equal(t, "<p>$verbatim$</p>\n", string(contents))
+
+	contents, err = os.ReadFile(filepath.Join(destination, "Foo", "static", "logo.bin"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x89, 'P', 'N', 'G', 0x00, 0x01, 0x02}, contents)
+
+	_, err = os.Stat(filepath.Join(destination, "Dockerfile"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(destination, "default.properties"))
+	assert.True(t, os.IsNotExist(err))
+
+	assert.EqualError(t, TemplateFS(p, templateFS(), destination, nil), fmt.Sprintf("destination %s already exists", destination))
+	assert.EqualError(t, TemplateFS(p, templateFS(), destination, &Options{Workers: 4, OnConflict: ConflictSkip}), fmt.Sprintf("destination %s already exists", destination))
+	assert.NoError(t, TemplateFS(p, templateFS(), destination, &Options{AllowExisting: true, OnConflict: ConflictSkip}))
+}
+
+func TestTemplateOutputMemory(t *testing.T) {
+	out := gfs.NewMemOutput()
+	p := props.Pairs{{K: "name", V: "Foo"}, {K: "docker", V: "yes"}, {K: "verbatim", V: "*.html"}}
+	require.NoError(t, TemplateOutput(p, templateFS(), out, nil))
+
+	assert.Equal(t, []string{
+		"Dockerfile",
+		"Foo",
+		"Foo/README.md",
+		"Foo/docs",
+		"Foo/docs/index.html",
+		"Foo/run.sh",
+		"Foo/static",
+		"Foo/static/logo.bin",
+	}, out.Names())
+
+	contents, err := out.ReadFile("Foo/README.md")
+	require.NoError(t, err)
+	assert.Equal(t, "Hello, Foo!\n", string(contents))
+
+	stat, err := out.Stat("Foo/run.sh")
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), stat.Mode())
+
+	// Post-processing and copying to disk
+	require.NoError(t, out.Remove("Dockerfile"))
+	require.NoError(t, gfs.WriteFile(out, "Foo/NOTICE", []byte("generated"), 0644))
+	destination := t.TempDir()
+	require.NoError(t, out.CopyTo(gfs.NewDirOutput(destination)))
+	contents, err = os.ReadFile(filepath.Join(destination, "Foo", "NOTICE"))
+	require.NoError(t, err)
+	assert.Equal(t, "generated", string(contents))
+	_, err = os.Stat(filepath.Join(destination, "Dockerfile"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestTemplateOutputRejectsEscapingPaths(t *testing.T) {
+	fsys := fstest.MapFS{"$name$/file.txt": {Data: []byte("contents")}}
+	err := TemplateOutput(props.Pairs{{K: "name", V: ".."}}, fsys, gfs.NewMemOutput(), nil)
+	assert.Error(t, err)
+}
+
+func TestTemplateArchive(t *testing.T) {
+	p := props.Pairs{{K: "name", V: "Foo"}, {K: "docker", V: "no"}, {K: "verbatim", V: "*.html"}}
+	expected := map[string]os.FileMode{
+		"Foo/":                0755 | os.ModeDir,
+		"Foo/README.md":       0644,
+		"Foo/docs/":           0755 | os.ModeDir,
+		"Foo/docs/index.html": 0644,
+		"Foo/run.sh":          0755,
+		"Foo/static/":         0755 | os.ModeDir,
+		"Foo/static/logo.bin": 0644,
+	}
+
+	t.Run("zip", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, TemplateArchive(p, templateFS(), &buf, gfs.ArchiveZip, nil))
+		r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		require.NoError(t, err)
+		found := map[string]os.FileMode{}
+		for _, f := range r.File {
+			found[f.Name] = f.Mode()
+			if f.Name == "Foo/README.md" {
+				rc, err := f.Open()
+				require.NoError(t, err)
+				data, err := io.ReadAll(rc)
+				require.NoError(t, err)
+				assert.Equal(t, "Hello, Foo!\n", string(data))
+			}
+		}
+		assert.Equal(t, expected, found)
+	})
+
+	t.Run("tar.gz", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, TemplateArchive(p, templateFS(), &buf, gfs.ArchiveTarGz, nil))
+		gz, err := gzip.NewReader(&buf)
+		require.NoError(t, err)
+		r := tar.NewReader(gz)
+		found := map[string]os.FileMode{}
+		for {
+			h, err := r.Next()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			found[h.Name] = h.FileInfo().Mode()
+			if h.Name == "Foo/run.sh" {
+				data, err := io.ReadAll(r)
+				require.NoError(t, err)
+				assert.Equal(t, "echo foo\n", string(data))
+			}
+		}
+		assert.Equal(t, expected, found)
+	})
+}
+
+func TestTemplateConflictPolicies(t *testing.T) {
+	fsys := fstest.MapFS{
+		"README.md": {Data: []byte("new $name$\n")},
+		"ci.yml":    {Data: []byte("ci\n")},
+	}
+	p := props.Pairs{{K: "name", V: "Foo"}}
+	prepare := func(t *testing.T) *gfs.MemOutput {
+		out := gfs.NewMemOutput()
+		require.NoError(t, gfs.WriteFile(out, "README.md", []byte("existing\n"), 0644))
+		return out
+	}
+	read := func(t *testing.T, out *gfs.MemOutput, name string) string {
+		data, err := out.ReadFile(name)
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	t.Run("fail", func(t *testing.T) {
+		out := prepare(t)
+		err := TemplateOutput(p, fsys, out, nil)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "README.md: destination file already exists")
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		out := prepare(t)
+		require.NoError(t, TemplateOutput(p, fsys, out, &Options{OnConflict: ConflictSkip}))
+		assert.Equal(t, "existing\n", read(t, out, "README.md"))
+		assert.Equal(t, "ci\n", read(t, out, "ci.yml"))
+	})
+
+	t.Run("overwrite", func(t *testing.T) {
+		out := prepare(t)
+		require.NoError(t, TemplateOutput(p, fsys, out, &Options{OnConflict: ConflictOverwrite}))
+		assert.Equal(t, "new Foo\n", read(t, out, "README.md"))
+	})
+
+	t.Run("keep-both", func(t *testing.T) {
+		out := prepare(t)
+		require.NoError(t, TemplateOutput(p, fsys, out, &Options{OnConflict: ConflictKeepBoth}))
+		assert.Equal(t, "existing\n", read(t, out, "README.md"))
+		assert.Equal(t, "new Foo\n", read(t, out, "README.md"+KeepBothSuffix))
+
+		// Previously kept files are never replaced
+		require.NoError(t, TemplateOutput(p, fsys, out, &Options{OnConflict: ConflictKeepBoth}))
+		assert.Equal(t, "new Foo\n", read(t, out, "README.md"+KeepBothSuffix))
+		assert.Equal(t, "new Foo\n", read(t, out, "README.md"+KeepBothSuffix+".1"))
+		assert.Equal(t, "ci\n", read(t, out, "ci.yml"+KeepBothSuffix))
+	})
+
+	t.Run("ask", func(t *testing.T) {
+		out := prepare(t)
+		var asked []string
+		opts := &Options{OnConflict: ConflictAsk, ConflictHandler: func(path string) (ConflictPolicy, error) {
+			asked = append(asked, path)
+			return ConflictOverwrite, nil
+		}}
+		require.NoError(t, TemplateOutput(p, fsys, out, opts))
+		assert.Equal(t, []string{"README.md"}, asked)
+		assert.Equal(t, "new Foo\n", read(t, out, "README.md"))
+
+		assert.Error(t, TemplateOutput(p, fsys, prepare(t), &Options{OnConflict: ConflictAsk}))
+	})
+}
+
+func TestParseConflictPolicy(t *testing.T) {
+	for _, p := range []ConflictPolicy{ConflictFail, ConflictSkip, ConflictOverwrite, ConflictKeepBoth, ConflictAsk} {
+		parsed, err := ParseConflictPolicy(p.String())
+		require.NoError(t, err)
+		assert.Equal(t, p, parsed)
+	}
+	_, err := ParseConflictPolicy("merge")
+	assert.Error(t, err)
+}
+
+func TestTemplateFSRollback(t *testing.T) {
+	fsys := fstest.MapFS{
+		"README.md":     {Data: []byte("Hello, $name$!\n"), Mode: 0644},
+		"src/main.go":   {Data: []byte("package $name$\n"), Mode: 0644},
+		"src/broken.go": {Data: []byte("package $undefined$\n"), Mode: 0644},
+	}
+	p := props.Pairs{{K: "name", V: "foo"}}
+	parent := t.TempDir()
+	entries := func() []string {
+		list, err := os.ReadDir(parent)
+		require.NoError(t, err)
+		var names []string
+		for _, e := range list {
+			names = append(names, e.Name())
+		}
+		return names
+	}
+
+	t.Run("new destination", func(t *testing.T) {
+		destination := filepath.Join(parent, "new")
+		err := TemplateFS(p, fsys, destination, nil)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "src/broken.go")
+		assert.Empty(t, entries())
+	})
+
+	t.Run("existing destination", func(t *testing.T) {
+		destination := filepath.Join(parent, "existing")
+		require.NoError(t, os.Mkdir(destination, 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(destination, "README.md"), []byte("existing\n"), 0644))
+
+		err := TemplateFS(p, fsys, destination, &Options{AllowExisting: true, OnConflict: ConflictOverwrite})
+		require.Error(t, err)
+		assert.Equal(t, []string{"existing"}, entries())
+
+		contents, err := os.ReadFile(filepath.Join(destination, "README.md"))
+		require.NoError(t, err)
+		assert.Equal(t, "existing\n", string(contents))
+		_, err = os.Stat(filepath.Join(destination, "src"))
+		assert.True(t, os.IsNotExist(err))
+
+		delete(fsys, "src/broken.go")
+		require.NoError(t, TemplateFS(p, fsys, destination, &Options{AllowExisting: true, OnConflict: ConflictOverwrite}))
+		assert.Equal(t, []string{"existing"}, entries())
+		contents, err = os.ReadFile(filepath.Join(destination, "README.md"))
+		require.NoError(t, err)
+		assert.Equal(t, "Hello, foo!\n", string(contents))
+		contents, err = os.ReadFile(filepath.Join(destination, "src", "main.go"))
+		require.NoError(t, err)
+		assert.Equal(t, "package foo\n", string(contents))
+	})
+}
+
+func TestTemplateOutputWorkers(t *testing.T) {
+	fsys := fstest.MapFS{}
+	for i := 0; i < 200; i++ {
+		fsys[fmt.Sprintf("$name$/dir%02d/file%03d.txt", i%10, i)] = &fstest.MapFile{Data: []byte(fmt.Sprintf("$name$ %d\n", i)), Mode: 0644}
+	}
+	p := props.Pairs{{K: "name", V: "Foo"}}
+
+	sequential := gfs.NewMemOutput()
+	require.NoError(t, TemplateOutput(p, fsys, sequential, nil))
+	parallel := gfs.NewMemOutput()
+	require.NoError(t, TemplateOutput(p, fsys, parallel, &Options{Workers: 8}))
+
+	require.Equal(t, sequential.Names(), parallel.Names())
+	for _, n := range sequential.Names() {
+		stat, err := sequential.Stat(n)
+		require.NoError(t, err)
+		if stat.IsDir() {
+			continue
+		}
+		expected, err := sequential.ReadFile(n)
+		require.NoError(t, err)
+		actual, err := parallel.ReadFile(n)
+		require.NoError(t, err)
+		assert.Equal(t, string(expected), string(actual))
+	}
+
+	// The first failing file in the plan is always the one reported
+	fsys["$name$/dir03/file050.txt"] = &fstest.MapFile{Data: []byte("$undefined$\n")}
+	fsys["$name$/dir07/file150.txt"] = &fstest.MapFile{Data: []byte("$undefined$\n")}
+	for i := 0; i < 10; i++ {
+		err := TemplateOutput(p, fsys, gfs.NewMemOutput(), &Options{Workers: 8})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "dir03/file050.txt")
+	}
+}
+
+func TestTemplateFSContext(t *testing.T) {
+	p := props.Pairs{{K: "name", V: "Foo"}, {K: "docker", V: "yes"}}
+
+	t.Run("cancelled before rendering", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		destination := filepath.Join(t.TempDir(), "out")
+		err := TemplateFSContext(ctx, p, templateFS(), destination, nil)
+		assert.Equal(t, context.Canceled, err)
+		_, err = os.Stat(destination)
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	for _, workers := range []int{1, 4} {
+		t.Run(fmt.Sprintf("cancelled while rendering with %d workers", workers), func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			opts := &Options{Workers: workers, AfterRenderCallback: func(_ os.FileInfo, contents string) (string, error) {
+				cancel()
+				return contents, nil
+			}}
+			parent := t.TempDir()
+			err := TemplateFSContext(ctx, p, templateFS(), filepath.Join(parent, "out"), opts)
+			assert.Equal(t, context.Canceled, err)
+
+			entries, err := os.ReadDir(parent)
+			require.NoError(t, err)
+			assert.Empty(t, entries)
+		})
+	}
+}
+
+func TestTemplateOutputComments(t *testing.T) {
+	fsys := fstest.MapFS{
+		"$name$$! keep this short !$.md": {Data: []byte("$! Rendered into the project README !$\n# $name$\n")},
+		"$invalid.md":                    {Data: []byte("kept\n")},
+	}
+	out := gfs.NewMemOutput()
+	require.NoError(t, TemplateOutput(props.Pairs{{K: "name", V: "Foo"}}, fsys, out, nil))
+	assert.Equal(t, []string{"$invalid.md", "Foo.md"}, out.Names())
+
+	contents, err := out.ReadFile("Foo.md")
+	require.NoError(t, err)
+	assert.Equal(t, "# Foo\n", string(contents))
+}
+
+func TestTemplateOutputDelimiters(t *testing.T) {
+	fsys := fstest.MapFS{
+		"run.sh":             {Data: []byte("#!/bin/sh\n# g8:delimiters {{ }}\necho \"$HOME\" {{name}}\n"), Mode: 0755},
+		"Makefile":           {Data: []byte("build:\n\tgo build -o $@ @@name@@\n")},
+		"charts/values.yaml": {Data: []byte("image: [[name]]\nport: $$PORT\n")},
+		"README.md":          {Data: []byte("# $name$\n")},
+	}
+	p := props.Pairs{{K: "name", V: "foo"}, {K: "delimiters", V: "charts/** [[ ]]"}}
+	out := gfs.NewMemOutput()
+	opts := &Options{Delimiters: []DelimiterRule{{Pattern: "Makefile", Delimiters: lexer.Delimiters{Open: "@@", Close: "@@"}}}}
+	require.NoError(t, TemplateOutput(p, fsys, out, opts))
+
+	read := func(name string) string {
+		data, err := out.ReadFile(name)
+		require.NoError(t, err)
+		return string(data)
+	}
+	assert.Equal(t, "#!/bin/sh\necho \"$HOME\" foo\n", read("run.sh"))
+	assert.Equal(t, "build:\n\tgo build -o $@ foo\n", read("Makefile"))
+	assert.Equal(t, "image: foo\nport: $$PORT\n", read("charts/values.yaml"))
+	assert.Equal(t, "# foo\n", read("README.md"))
+
+	p = props.Pairs{{K: "name", V: "foo"}, {K: "delimiters", V: "charts/** [["}}
+	assert.Error(t, TemplateOutput(p, fsys, gfs.NewMemOutput(), nil))
+	p = props.Pairs{{K: "name", V: "foo"}, {K: "delimiters", V: "!README.md [[ ]]"}}
+	err := TemplateOutput(p, fsys, gfs.NewMemOutput(), nil)
+	assert.EqualError(t, err, "invalid delimiter rule: invalid pattern `!README.md': negated patterns are not supported here")
+}
+
+func TestTemplateFSExclude(t *testing.T) {
+	fsys := templateFS()
+	fsys[gfs.IgnoreFile] = &fstest.MapFile{Data: []byte("static/\n")}
+	p := props.Pairs{{K: "name", V: "Foo"}, {K: "exclude", V: "*.sh docs/"}}
+
+	out := gfs.NewMemOutput()
+	require.NoError(t, TemplateOutput(p, fsys, out, nil))
+	assert.Equal(t, []string{"Foo", "Foo/README.md"}, out.Names())
+
+	p = props.Pairs{{K: "name", V: "Foo"}, {K: "exclude", V: "[docs"}}
+	err := TemplateOutput(p, fsys, gfs.NewMemOutput(), nil)
+	assert.EqualError(t, err, "invalid exclude property: invalid pattern `[docs': unterminated character class")
+}
@@ -1,33 +1,119 @@
 package render
 
 import (
-	"regexp"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/gympass/go-giter8/fs"
+	"github.com/gympass/go-giter8/props"
 )
 
 func TestVerbatim(t *testing.T) {
-	rawPatterns := []string{"*.css", "*.html", "foobar.xml", "test/foo/bar.c"}
+	rawPatterns := []string{"*.css", "*.html", "!index.html", "foobar.xml", "test/foo/bar.c", "assets/"}
 	expectations := map[string]bool{
-		"hello/foo/bar.c":            false,
-		"file.css":                   true,
-		"foo/bar.css":                true,
-		"a/longer/path/to/file.html": true,
-		"something.go":               false,
-		"foobar.xml":                 true,
-		"other.xml":                  false,
-		"/something/test/foo/bar.c":  true,
-	}
-	var patterns []*regexp.Regexp
-	for _, r := range rawPatterns {
-		patterns = append(patterns, fs.CreateSGlob(r))
+		"hello/foo/bar.c":             false,
+		"file.css":                    true,
+		"foo/bar.css":                 true,
+		"a/longer/path/to/file.html":  true,
+		"a/longer/path/to/index.html": false,
+		"something.go":                false,
+		"foobar.xml":                  true,
+		"other.xml":                   false,
+		"test/foo/bar.c":              true,
+		"/something/test/foo/bar.c":   false,
+		"assets/logo.svg":             true,
+		"src/assets/icons/logo.svg":   true,
 	}
+	patterns, err := fs.CompileGlobSet(rawPatterns)
+	require.NoError(t, err)
 	for k, v := range expectations {
 		t.Run(k, func(t *testing.T) {
 			assert.Equal(t, v, isVerbatim(k, patterns))
 		})
 	}
 }
+
+func TestVerbatimInvalid(t *testing.T) {
+	p := props.Pairs{{K: "name", V: "Foo"}, {K: "verbatim", V: "*.html [oops"}}
+	err := TemplateFS(p, templateFS(), filepath.Join(t.TempDir(), "out"), nil)
+	assert.EqualError(t, err, "invalid verbatim property: invalid pattern `[oops': unterminated character class")
+}
+
+func TestVerbatimRelativePaths(t *testing.T) {
+	// The template lives in a directory named "docs", which must not be taken
+	// into account when matching patterns.
+	source := filepath.Join(t.TempDir(), "docs")
+	files := map[string]string{
+		"README.md":              "$name$",
+		"docs/index.md":          "$name$",
+		"docs/api/reference.md":  "$name$",
+		"src/docs/notes.md":      "$name$",
+		"src/main/app.tmpl":      "$name$",
+		"src/main/deep/app.tmpl": "$name$",
+		"other.txt":              "$name$",
+	}
+	for name, contents := range files {
+		p := filepath.Join(source, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		require.NoError(t, os.WriteFile(p, []byte(contents), 0644))
+	}
+
+	tests := map[string]map[string]bool{
+		"/docs/*.md": {
+			"README.md": false, "docs/index.md": true, "docs/api/reference.md": false,
+			"src/docs/notes.md": false, "other.txt": false,
+		},
+		"docs/**/*.md": {
+			"README.md": false, "docs/index.md": true, "docs/api/reference.md": true,
+			"src/docs/notes.md": false, "other.txt": false,
+		},
+		"**/docs/*.md": {
+			"README.md": false, "docs/index.md": true, "docs/api/reference.md": false,
+			"src/docs/notes.md": true, "other.txt": false,
+		},
+		"src/main/**": {
+			"src/main/app.tmpl": true, "src/main/deep/app.tmpl": true, "src/docs/notes.md": false,
+		},
+		"*.txt": {
+			"other.txt": true, "README.md": false,
+		},
+	}
+	for pattern, expectations := range tests {
+		t.Run(pattern, func(t *testing.T) {
+			destination := filepath.Join(t.TempDir(), "out")
+			p := props.Pairs{{K: "name", V: "Foo"}, {K: "verbatim", V: pattern}}
+			require.NoError(t, TemplateDirectory(p, source, destination))
+			for name, verbatim := range expectations {
+				contents, err := os.ReadFile(filepath.Join(destination, filepath.FromSlash(name)))
+				require.NoError(t, err)
+				expected := "Foo"
+				if verbatim {
+					expected = "$name$"
+				}
+				assert.Equal(t, expected, string(contents), name)
+			}
+		})
+	}
+}
+
+func TestDelimitersRelativePaths(t *testing.T) {
+	source := filepath.Join(t.TempDir(), "scripts")
+	require.NoError(t, os.MkdirAll(filepath.Join(source, "scripts"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(source, "run.sh"), []byte("$name$"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(source, "scripts", "run.sh"), []byte("{{name}}"), 0644))
+
+	destination := filepath.Join(t.TempDir(), "out")
+	p := props.Pairs{{K: "name", V: "Foo"}, {K: "delimiters", V: "/scripts/*.sh {{ }}"}}
+	require.NoError(t, TemplateDirectory(p, source, destination))
+
+	contents, err := os.ReadFile(filepath.Join(destination, "run.sh"))
+	require.NoError(t, err)
+	assert.Equal(t, "Foo", string(contents))
+	contents, err = os.ReadFile(filepath.Join(destination, "scripts", "run.sh"))
+	require.NoError(t, err)
+	assert.Equal(t, "Foo", string(contents))
+}
@@ -19,6 +19,13 @@ func (sb *StringBuilder) WriteRune(r rune) {
 	sb.cur++
 }
 
+// WriteString appends all runes of a given string to the StringBuilder
+func (sb *StringBuilder) WriteString(s string) {
+	for _, r := range s {
+		sb.WriteRune(r)
+	}
+}
+
 // DeleteLast deletes the last rune in this StringBuilder
 func (sb *StringBuilder) DeleteLast() {
 	sb.Delete(1)
@@ -30,7 +30,7 @@ $endif$`
 	exec := render.NewExecutor(p)
 	r, err := exec.Exec(ast)
 	require.NoError(t, err)
-	assert.Equal(t, "\nOK!\n", r)
+	assert.Equal(t, "OK!\n", r)
 }
 
 func TestNestedConditionals(t *testing.T) {
@@ -73,7 +73,7 @@ $endif$`
 	exec := render.NewExecutor(p)
 	r, err := exec.Exec(ast)
 	require.NoError(t, err)
-	assert.Equal(t, "\nYay!\n", r)
+	assert.Equal(t, "Yay!\n", r)
 
 }
 
@@ -95,6 +95,33 @@ $endif$`
 	exec := render.NewExecutor(p)
 	r, err := exec.Exec(ast)
 	require.NoError(t, err)
-	assert.Equal(t, "\nfoobar\n", r)
+	assert.Equal(t, "foobar\n", r)
 
 }
+
+func TestStandaloneControlLines(t *testing.T) {
+	template := "services:\n" +
+		"  app:\n" +
+		"    image: $image$\n" +
+		"    $if(db.truthy)$\n" +
+		"    depends_on:\n" +
+		"      - db\n" +
+		"    $else$  \r\n" +
+		"    restart: always\n" +
+		"    $endif$\n" +
+		"  $if(db.truthy)$db: {}$endif$\n" +
+		"$if(db.truthy)$\n" +
+		"# with db\n" +
+		"$endif$"
+
+	ast, err := lexer.Tokenize(template)
+	require.NoError(t, err)
+
+	r, err := render.NewExecutor(props.Pairs{{K: "image", V: "app"}, {K: "db", V: "yes"}}).Exec(ast)
+	require.NoError(t, err)
+	assert.Equal(t, "services:\n  app:\n    image: app\n    depends_on:\n      - db\n  db: {}\n# with db\n", r)
+
+	r, err = render.NewExecutor(props.Pairs{{K: "image", V: "app"}, {K: "db", V: "no"}}).Exec(ast)
+	require.NoError(t, err)
+	assert.Equal(t, "services:\n  app:\n    image: app\n    restart: always\n  \n", r)
+}